@@ -0,0 +1,870 @@
+package apc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/L11R/go-apc/apctest"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// newHandshakedClient returns the *Client that has received the hello over in-memory pipe;
+// after the hello the server end of the pipe is passed to serve.
+func newHandshakedClient(t *testing.T, serve func(server net.Conn)) (*Client, net.Conn) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		if _, err := serverConn.Write([]byte(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP"))); err != nil {
+			return
+		}
+		serve(serverConn)
+	}()
+
+	c := newClient("pipe", &Options{})
+	if err := c.handshake(context.Background(), clientConn); err != nil {
+		t.Fatalf("c.handshake() error = %v", err)
+	}
+
+	return c, serverConn
+}
+
+func TestNewClient_DialContext(t *testing.T) {
+	errDial := errors.New("dial error")
+
+	var gotAddr string
+	_, err := NewClient("apc.example.com:22700", WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		gotAddr = addr
+		return nil, errDial
+	}))
+	if !errors.Is(err, errDial) {
+		t.Errorf("NewClient() error = %v, want %v", err, errDial)
+	}
+
+	if gotAddr != "apc.example.com:22700" {
+		t.Errorf("addr = %v, want apc.example.com:22700", gotAddr)
+	}
+}
+
+func TestNewClient_TLSConfig(t *testing.T) {
+	srv := apctest.NewServer()
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "standard"},
+		{name: "patched", opts: []Option{WithTlsPatched()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verified bool
+			config := &tls.Config{
+				// Server certificate is self-signed, verification is done by the callback
+				InsecureSkipVerify: true,
+				VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+					verified = len(rawCerts) > 0
+					return nil
+				},
+			}
+
+			c, err := NewClient(srv.Addr, append(tt.opts, WithTLSConfig(config))...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			defer c.Stop()
+
+			go func() {
+				_ = c.Start()
+			}()
+
+			if err := c.AvailWork(context.Background()); err != nil {
+				t.Errorf("c.AvailWork() error = %v", err)
+			}
+
+			if !verified {
+				t.Errorf("VerifyPeerCertificate of the passed config is not called")
+			}
+
+			if config.ServerName != "" {
+				t.Errorf("config.ServerName = %v, passed config should not be modified", config.ServerName)
+			}
+		})
+	}
+}
+
+func TestNewClientWithConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	go func() {
+		if _, err := serverConn.Write([]byte(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP"))); err != nil {
+			return
+		}
+
+		buf := make([]byte, 4096)
+		n, err := serverConn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		command, err := decodeEvent(string(buf[:n]))
+		if err != nil {
+			return
+		}
+
+		_, _ = serverConn.Write([]byte(rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")))
+	}()
+
+	c, err := NewClientWithConn(clientConn, WithAutoReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClientWithConn() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start()
+	}()
+
+	if err := c.AvailWork(context.Background()); err != nil {
+		t.Errorf("c.AvailWork() error = %v", err)
+	}
+
+	// Passed connection cannot be re-established, so the Client stops
+	_ = serverConn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("c.Start() is not returned after the connection is closed")
+	}
+
+	if got := c.State(); got != ConnClosed {
+		t.Errorf("c.State() = %v, want %v", got, ConnClosed)
+	}
+}
+
+func TestDevelopmentLogger(t *testing.T) {
+	options := &Options{}
+	DevelopmentLogger()(options)
+
+	if options.LogLevel != LogLevelDebug || options.LogHandler == nil {
+		t.Errorf("options.LogLevel = %v, want %v with handler", options.LogLevel, LogLevelDebug)
+	}
+
+	var buf bytes.Buffer
+	handler := newConsoleLogHandler(&buf)
+	handler(newLogEntry(LogLevelError, "Error while reconnecting!", map[string]interface{}{"error": "EOF", "attempt": 2}))
+
+	if want := `ERROR Error while reconnecting! attempt="2" error="EOF"` + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("log = %q, want suffix %q", buf.String(), want)
+	}
+}
+
+func TestClient_handshake_Deadline(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := newClient("pipe", &Options{})
+	err := c.handshake(ctx, clientConn)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("c.handshake() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if errors.Is(err, ErrHelloNotReceived) {
+		t.Errorf("c.handshake() error = %v, must not be %v", err, ErrHelloNotReceived)
+	}
+}
+
+// newLoopbackClient starts the *Client with the passed options connected to the server over loopback TCP connection,
+// Start result is sent to the returned error channel. Unlike net.Pipe, TCP connection fails to close twice.
+// The server answers the commands if respond is true, keywords of the commands are sent to the returned channel.
+func newLoopbackClient(t *testing.T, opts *Options, respond bool) (*Client, <-chan string, <-chan error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	commands := make(chan string, 100)
+	go func() {
+		serverConn, err := listener.Accept()
+		_ = listener.Close()
+		if err != nil {
+			return
+		}
+		defer serverConn.Close()
+
+		if _, err := serverConn.Write([]byte(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP"))); err != nil {
+			return
+		}
+
+		br := bufio.NewReader(serverConn)
+		for {
+			frame, err := br.ReadString(ETX)
+			if err != nil {
+				return
+			}
+
+			command, err := decodeEvent(frame)
+			if err != nil {
+				return
+			}
+			commands <- command.Keyword
+
+			if !respond {
+				continue
+			}
+
+			response := rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")
+			if command.Keyword == "AGTListState" {
+				response = rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "S70000") + response
+			}
+			if _, err := serverConn.Write([]byte(response)); err != nil {
+				return
+			}
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+
+	c := newClient("", opts)
+	if err := c.handshake(context.Background(), clientConn); err != nil {
+		t.Fatalf("c.handshake() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start()
+	}()
+
+	return c, commands, done
+}
+
+func TestClient_KeepAlive_Failed(t *testing.T) {
+	c, commands, done := newLoopbackClient(t, &Options{KeepAliveInterval: 50 * time.Millisecond}, false)
+	defer c.Stop()
+
+	notifications := c.Subscribe(context.Background())
+
+	// Server never responds, so the command stays in flight until the connection is closed
+	executed := make(chan error, 1)
+	go func() {
+		_, err := c.Execute(context.Background(), "AGTCustom")
+		executed <- err
+	}()
+	for keyword := range commands {
+		if keyword == "AGTCustom" {
+			break
+		}
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("c.Start() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection is not closed after failed keepalive")
+	}
+
+	select {
+	case err := <-executed:
+		if err == nil {
+			t.Errorf("c.Execute() error = nil, want the error of closed connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("in-flight command is not released")
+	}
+
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Errorf("notification is received, want closed channel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("subscriber channel is not closed")
+	}
+}
+
+func TestClient_IdleTimeout(t *testing.T) {
+	c, commands, done := newLoopbackClient(t, &Options{IdleTimeout: 20 * time.Millisecond, ReadTimeout: time.Second}, true)
+	defer c.Stop()
+
+	// Quiet connection is checked with the heartbeat instead of being closed
+	select {
+	case keyword := <-commands:
+		if keyword != "AGTListState" {
+			t.Errorf("heartbeat keyword = %v, want AGTListState", keyword)
+		}
+	case err := <-done:
+		t.Fatalf("c.Start() error = %v, connection must survive the idle timeout", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("heartbeat is not sent")
+	}
+
+	if err := c.AvailWork(context.Background()); err != nil {
+		t.Errorf("c.AvailWork() error = %v", err)
+	}
+}
+
+func TestClient_IdleTimeout_HeartbeatFailed(t *testing.T) {
+	c, _, done := newLoopbackClient(t, &Options{IdleTimeout: 30 * time.Millisecond, ReadTimeout: 30 * time.Millisecond}, false)
+	defer c.Stop()
+
+	notifications := c.Subscribe(context.Background())
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("c.Start() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection is not closed after unanswered heartbeat")
+	}
+
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Errorf("notification is received, want closed channel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("subscriber channel is not closed")
+	}
+}
+
+func TestClient_handshake_KeepAliveErr(t *testing.T) {
+	c := newClient("pipe", &Options{})
+
+	// Probe of the lost connection has failed after its error was reported
+	c.keepAliveErr.Store(context.DeadlineExceeded)
+
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		_, _ = serverConn.Write([]byte(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP")))
+	}()
+
+	if err := c.handshake(context.Background(), clientConn); err != nil {
+		t.Fatalf("c.handshake() error = %v", err)
+	}
+
+	if err := c.keepAliveErr.Load(); err != nil {
+		t.Errorf("c.keepAliveErr = %v, want nil for the new connection", err)
+	}
+}
+
+func TestClient_StartContext(t *testing.T) {
+	srv := apctest.NewServer()
+	defer srv.Close()
+
+	// Server never responds, so the command stays in flight until the event loop stops
+	srv.Handle("AGTEchoOn", func(cmd apctest.Command) []apctest.Event {
+		return nil
+	})
+
+	c, err := NewClient(srv.Addr, WithTlsSkipVerify())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.StartContext(ctx)
+	}()
+
+	notifications := c.Subscribe(context.Background())
+	executed := make(chan error, 1)
+	go func() {
+		executed <- c.EchoOn(context.Background())
+	}()
+	for len(srv.Commands()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("c.StartContext() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("c.StartContext() doesn't return after the context is cancelled")
+	}
+
+	if got := c.State(); got != ConnClosed {
+		t.Errorf("c.State() = %v, want %v", got, ConnClosed)
+	}
+
+	select {
+	case err := <-executed:
+		if err == nil {
+			t.Errorf("c.EchoOn() error = nil, want the error of stopped Client")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("in-flight command is not released")
+	}
+
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Errorf("notification is received, want closed channel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("subscriber channel is not closed")
+	}
+}
+
+func TestClient_Stop(t *testing.T) {
+	c, server := newHandshakedClient(t, func(server net.Conn) {
+		_, _ = io.Copy(ioutil.Discard, server)
+	})
+	defer server.Close()
+
+	started := make(chan error)
+	go func() {
+		started <- c.Start()
+	}()
+
+	// The request will never get a response
+	requested := make(chan error)
+	go func() {
+		_, err := c.ListState(context.Background())
+		requested <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := c.Stop(); err != nil {
+		t.Errorf("c.Stop() error = %v, want nil", err)
+	}
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Errorf("c.Start() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("c.Start() wasn't unblocked by c.Stop()")
+	}
+
+	select {
+	case err := <-requested:
+		if err == nil {
+			t.Error("c.ListState() error = nil, want an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("c.ListState() wasn't cancelled by c.Stop()")
+	}
+
+	// Stop is idempotent
+	if err := c.Stop(); err != nil {
+		t.Errorf("second c.Stop() error = %v, want nil", err)
+	}
+}
+
+func TestClient_readEvents_Fragmented(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	stream := rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP") +
+		rawEvent("AGTListJobs", EventTypeData, 1, "0", "M00001", "O,outbound1,A", "I,inbound1,I") +
+		rawEvent("AGTListJobs", EventTypeResponse, 1, "0", "M00000")
+
+	// Every Read returns a single byte, so each event spans many reads
+	done := make(chan error)
+	go func() {
+		done <- c.readEvents(server, iotest.OneByteReader(strings.NewReader(stream)))
+	}()
+
+	want := []Event{
+		{Keyword: "AGTSTART", Type: EventTypeNotification, Client: "Agent server", ProcessID: 2570, Segments: []string{"0", "AGENT_STARTUP"}},
+		{Keyword: "AGTListJobs", Type: EventTypeData, Client: "Agent server", ProcessID: 2570, InvokeID: 1, Segments: []string{"0", "M00001", "O,outbound1,A", "I,inbound1,I"}},
+		{Keyword: "AGTListJobs", Type: EventTypeResponse, Client: "Agent server", ProcessID: 2570, InvokeID: 1, Segments: []string{"0", "M00000"}},
+	}
+	for _, w := range want {
+		if got := <-c.events; !reflect.DeepEqual(got, w) {
+			t.Errorf("event = %#v, want %#v", got, w)
+		}
+	}
+
+	if err := <-done; err != ErrConnectionClosed {
+		t.Errorf("readEvents() error = %v, want %v", err, ErrConnectionClosed)
+	}
+}
+
+func TestClient_readEvents_Batched(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	// Both events are returned by the single Read
+	stream := rawEvent("AGTLogon", EventTypeResponse, 1, "0", "M00000") +
+		rawEvent("AGTLogoff", EventTypeResponse, 2, "0", "M00000")
+
+	done := make(chan error)
+	go func() {
+		done <- c.readEvents(server, strings.NewReader(stream))
+	}()
+
+	for _, keyword := range []string{"AGTLogon", "AGTLogoff"} {
+		if got := <-c.events; got.Keyword != keyword {
+			t.Errorf("event.Keyword = %v, want %v", got.Keyword, keyword)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("readEvents() error = %v, want nil", err)
+	}
+}
+
+func TestClient_Notifications(t *testing.T) {
+	c := newClient("pipe", &Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := c.Notifications(ctx)
+	if second := c.Notifications(context.Background()); second != first {
+		t.Errorf("c.Notifications() returned new channel while the first one is open")
+	}
+
+	cancel()
+	if _, ok := <-first; ok {
+		t.Errorf("c.Notifications() channel is not closed after ctx is done")
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	c := newClient("pipe", &Options{})
+
+	first := c.Subscribe(context.Background())
+	second := c.Subscribe(context.Background())
+
+	c.mu.RLock()
+	r := c.requests[math.MaxUint32]
+	c.mu.RUnlock()
+
+	r.eventChan <- Event{Keyword: "AGTJobEnd", Type: EventTypeNotification, Segments: []string{"0", "M00000"}}
+
+	for _, ch := range []<-chan Notification{first, second} {
+		if n := <-ch; n.Type != NotificationTypeJobEnd {
+			t.Errorf("n.Type = %v, want %v", n.Type, NotificationTypeJobEnd)
+		}
+	}
+
+	// Stopped Client closes all subscribers
+	c.conn, _ = net.Pipe()
+	_ = c.Stop()
+	c.cleanup()
+
+	for _, ch := range []<-chan Notification{first, second} {
+		if _, ok := <-ch; ok {
+			t.Errorf("subscriber channel is not closed after the Client is stopped")
+		}
+	}
+}
+
+func TestClient_broadcast_Overflow(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   NotificationType
+	}{
+		{policy: OverflowDropNewest, want: NotificationTypeJobEnd},
+		{policy: OverflowDropOldest, want: NotificationTypeCallNotify},
+	}
+
+	for _, tt := range tests {
+		c := newClient("pipe", &Options{NotificationBuffer: 1, OverflowPolicy: tt.policy})
+		ch := c.Subscribe(context.Background())
+
+		c.broadcast(Notification{Type: NotificationTypeJobEnd})
+		c.broadcast(Notification{Type: NotificationTypeCallNotify})
+
+		if n := <-ch; n.Type != tt.want {
+			t.Errorf("policy %v: n.Type = %v, want %v", tt.policy, n.Type, tt.want)
+		}
+
+		if got := c.DroppedNotifications(); got != 1 {
+			t.Errorf("policy %v: c.DroppedNotifications() = %v, want 1", tt.policy, got)
+		}
+	}
+}
+
+func TestClient_DrainNotifications(t *testing.T) {
+	c := newClient("pipe", &Options{})
+	if got := c.DrainNotifications(context.Background()); got != nil {
+		t.Errorf("c.DrainNotifications() = %v, want nil without Notifications channel", got)
+	}
+
+	_ = c.Notifications(context.Background())
+	c.broadcast(Notification{Type: NotificationTypeJobEnd})
+	c.broadcast(Notification{Type: NotificationTypeAutoReleaseLine})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := c.DrainNotifications(ctx); len(got) != 0 {
+		t.Errorf("c.DrainNotifications() = %v, want none after cancellation", got)
+	}
+
+	got := c.DrainNotifications(context.Background())
+	want := []Notification{{Type: NotificationTypeJobEnd}, {Type: NotificationTypeAutoReleaseLine}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("c.DrainNotifications() = %v, want %v", got, want)
+	}
+}
+
+func TestClient_Subscribe_JobEnd(t *testing.T) {
+	c := newClient("pipe", &Options{})
+	c.attachedJob.Store("outbound1")
+
+	ch := c.Subscribe(context.Background())
+
+	c.mu.RLock()
+	r := c.requests[math.MaxUint32]
+	c.mu.RUnlock()
+
+	r.eventChan <- Event{Keyword: "AGTJobEnd", Type: EventTypeNotification, Segments: []string{"0", "M00000"}}
+
+	n := <-ch
+	if want := (&JobEnd{AttachedJob: "outbound1"}); !reflect.DeepEqual(n.Payload, want) {
+		t.Errorf("n.Payload = %#v, want %#v", n.Payload, want)
+	}
+}
+
+func TestClient_Subscribe_HeadsetConnBroken(t *testing.T) {
+	c := newClient("pipe", &Options{})
+	c.reservedHeadset.Store(1001)
+
+	ch := c.Subscribe(context.Background())
+
+	c.mu.RLock()
+	r := c.requests[math.MaxUint32]
+	c.mu.RUnlock()
+
+	r.eventChan <- Event{Keyword: "AGTHeadsetConnBroken", Type: EventTypeNotification, Segments: []string{"1", "E28880"}}
+
+	n := <-ch
+	want := &HeadsetConnBroken{ReservedHeadset: 1001, Err: AvayaError{Code: "E28880"}}
+	if !reflect.DeepEqual(n.Payload, want) {
+		t.Errorf("n.Payload = %#v, want %#v", n.Payload, want)
+	}
+}
+
+func TestClient_StateChanged(t *testing.T) {
+	c, server := newHandshakedClient(t, func(server net.Conn) {})
+	defer server.Close()
+
+	if got := c.State(); got != ConnOK {
+		t.Errorf("c.State() = %v, want %v", got, ConnOK)
+	}
+
+	_ = c.Stop()
+
+	for _, want := range []ConnState{ConnOK, ConnClosed} {
+		if got := <-c.StateChanged(); got != want {
+			t.Errorf("<-c.StateChanged() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestClient_Notifications_Reconnect(t *testing.T) {
+	srv := apctest.NewServer()
+	defer srv.Close()
+
+	c, err := NewClient(srv.Addr, WithTlsSkipVerify(), WithAutoReconnect(3, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Stop()
+
+	notifications := c.Notifications(context.Background())
+	go func() {
+		_ = c.Start()
+	}()
+
+	srv.CloseClients()
+
+	for _, want := range []ConnState{ConnOK, ConnReconnecting, ConnOK} {
+		select {
+		case got := <-c.StateChanged():
+			if got != want {
+				t.Fatalf("<-c.StateChanged() = %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("<-c.StateChanged() timed out, want %v", want)
+		}
+	}
+
+	if err := srv.Notify("AGTJobEnd"); err != nil {
+		t.Fatalf("srv.Notify() error = %v", err)
+	}
+
+	select {
+	case n := <-notifications:
+		if n.Type != NotificationTypeJobEnd {
+			t.Errorf("n.Type = %v, want %v", n.Type, NotificationTypeJobEnd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("notification is not received after reconnect")
+	}
+}
+
+func TestClient_reconnect_HelloNotSent(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		stop    bool
+	}{
+		{name: "stopped", timeout: time.Minute, stop: true},
+		{name: "timed out", timeout: 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("net.Listen() error = %v", err)
+			}
+			defer listener.Close()
+
+			// The first connection is lost, the server accepts the next ones but never sends the hello
+			redialed := make(chan struct{}, 1)
+			go func() {
+				var conns []net.Conn
+				for {
+					serverConn, err := listener.Accept()
+					if err != nil {
+						for _, conn := range conns {
+							_ = conn.Close()
+						}
+						return
+					}
+					conns = append(conns, serverConn)
+
+					select {
+					case redialed <- struct{}{}:
+					default:
+					}
+				}
+			}()
+
+			clientConn, serverConn := net.Pipe()
+			go func() {
+				_, _ = serverConn.Write([]byte(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP")))
+			}()
+
+			c := newClient(listener.Addr().String(), &Options{
+				ReconnectMaxRetries: 1,
+				ReconnectBackoff:    time.Millisecond,
+				ReconnectTimeout:    tt.timeout,
+			})
+			if err := c.handshake(context.Background(), clientConn); err != nil {
+				t.Fatalf("c.handshake() error = %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- c.Start()
+			}()
+
+			_ = serverConn.Close()
+			select {
+			case <-redialed:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("the Client hasn't redialed the server")
+			}
+
+			if tt.stop {
+				_ = c.Stop()
+			}
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("c.Start() hasn't returned")
+			}
+
+			if got := c.State(); got != ConnClosed {
+				t.Errorf("c.State() = %v, want %v", got, ConnClosed)
+			}
+		})
+	}
+}
+
+func TestClient_readEvents_Decoder(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	go func() {
+		if _, err := serverConn.Write([]byte(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP"))); err != nil {
+			return
+		}
+
+		buf := make([]byte, 4096)
+		n, err := serverConn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		command, err := decodeEvent(string(buf[:n]))
+		if err != nil {
+			return
+		}
+
+		data, err := charmap.Windows1251.NewEncoder().String(rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "I,Входящие,A"))
+		if err != nil {
+			return
+		}
+		response := rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")
+
+		// Split the frames in the middle of the job name
+		raw := data + response
+		i := strings.IndexByte(raw, ',') + 3
+		for _, part := range []string{raw[:i], raw[i:]} {
+			if _, err := serverConn.Write([]byte(part)); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := newClient("pipe", &Options{Decoder: charmap.Windows1251.NewDecoder()})
+	if err := c.handshake(context.Background(), clientConn); err != nil {
+		t.Fatalf("c.handshake() error = %v", err)
+	}
+
+	go func() {
+		_ = c.Start()
+	}()
+	defer c.Stop()
+
+	jobs, err := c.ListJobs(context.Background(), JobTypeAll)
+	if err != nil {
+		t.Fatalf("c.ListJobs() error = %v", err)
+	}
+
+	want := []Job{{Type: JobTypeInbound, Name: "Входящие", Status: StatusTypeActive}}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Errorf("c.ListJobs() = %#v, want %#v", jobs, want)
+	}
+}
@@ -0,0 +1,368 @@
+// Package apctest provides a fake APC server for testing code that works with Agent API.
+//
+// Server speaks the same wire protocol as Avaya Proactive Contact does: it greets each client with
+// AGTSTART notification, answers commands with scripted responses and sends notifications on demand.
+//
+//	srv := apctest.NewServer()
+//	defer srv.Close()
+//
+//	srv.Respond("AGTListJobs", []string{"O,outbound1,A", "I,inbound1,I"})
+//	srv.Fail("AGTAttachJob", "E28804")
+//
+//	client, err := apc.NewClient(srv.Addr, apc.WithTlsSkipVerify())
+package apctest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RS is Message separator
+	RS byte = 0x1E
+	// ETB is IsIncomplete message separator
+	ETB byte = 0x17
+	// ETX is End of text
+	ETX byte = 0x03
+)
+
+// Event types used by the server.
+const (
+	TypePending      byte = 'P'
+	TypeData         byte = 'D'
+	TypeResponse     byte = 'R'
+	TypeBusy         byte = 'B'
+	TypeNotification byte = 'N'
+)
+
+// Command is the command received from a client.
+type Command struct {
+	Keyword  string
+	Client   string
+	InvokeID uint32
+	Segments []string
+}
+
+// Event is the event sent to a client.
+type Event struct {
+	Keyword    string
+	Type       byte
+	InvokeID   uint32
+	Segments   []string
+	Incomplete bool
+}
+
+// Handler returns events to send in response to the command.
+type Handler func(cmd Command) []Event
+
+// Server is a fake APC server listening on the loopback interface.
+type Server struct {
+	// Addr is the address of the server in host:port form
+	Addr string
+
+	listener net.Listener
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	commands []Command
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer starts and returns a new Server, the caller should call Close when finished.
+// Server uses self-signed certificate, so the client should skip TLS verification.
+func NewServer() *Server {
+	cert, err := newCertificate()
+	if err != nil {
+		panic(fmt.Sprintf("apctest: cannot generate certificate: %v", err))
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS10,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("apctest: cannot listen: %v", err))
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		handlers: make(map[string]Handler),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+
+	return s
+}
+
+// Handle registers the handler for the command keyword.
+// Commands without a handler are completed successfully without any data.
+func (s *Server) Handle(keyword string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[keyword] = handler
+}
+
+// Respond makes the server answer the command with a data message per each passed data
+// and then complete it successfully.
+func (s *Server) Respond(keyword string, data ...[]string) {
+	s.Handle(keyword, func(cmd Command) []Event {
+		events := make([]Event, 0, len(data)+1)
+		for _, d := range data {
+			events = append(events, Event{
+				Keyword:  cmd.Keyword,
+				Type:     TypeData,
+				InvokeID: cmd.InvokeID,
+				Segments: append([]string{"0", "M00001"}, d...),
+			})
+		}
+
+		return append(events, Event{
+			Keyword:  cmd.Keyword,
+			Type:     TypeResponse,
+			InvokeID: cmd.InvokeID,
+			Segments: []string{"0", "M00000"},
+		})
+	})
+}
+
+// Fail makes the server answer the command with the error code, e.g. E28804.
+func (s *Server) Fail(keyword string, code string) {
+	s.Handle(keyword, func(cmd Command) []Event {
+		return []Event{{
+			Keyword:  cmd.Keyword,
+			Type:     TypeResponse,
+			InvokeID: cmd.InvokeID,
+			Segments: []string{"1", code},
+		}}
+	})
+}
+
+// Notify sends the notification to all connected clients: a data message per each passed data
+// and then the completion message.
+func (s *Server) Notify(keyword string, data ...[]string) error {
+	events := make([]Event, 0, len(data)+1)
+	for _, d := range data {
+		events = append(events, Event{
+			Keyword:  keyword,
+			Type:     TypeNotification,
+			Segments: append([]string{"0", "M00001"}, d...),
+		})
+	}
+	events = append(events, Event{
+		Keyword:  keyword,
+		Type:     TypeNotification,
+		Segments: []string{"0", "M00000"},
+	})
+
+	return s.Send(events...)
+}
+
+// Send sends raw events to all connected clients.
+func (s *Server) Send(events ...Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		for _, event := range events {
+			if _, err := conn.Write(Encode(event)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Commands returns all commands received by the server so far.
+func (s *Server) Commands() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Command(nil), s.commands...)
+}
+
+// CloseClients closes connections of all connected clients, but keeps the server running.
+func (s *Server) CloseClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Close shuts down the server and closes all connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.CloseClients()
+	s.wg.Wait()
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	// Greet the client the same way as APC server does
+	hello := Event{Keyword: "AGTSTART", Type: TypeNotification, Segments: []string{"0", "AGENT_STARTUP"}}
+	if _, err := conn.Write(Encode(hello)); err != nil {
+		return
+	}
+
+	buf := make([]byte, 4096)
+	var pending []byte
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		pending = append(pending, buf[:n]...)
+
+		for {
+			i := bytes.IndexByte(pending, ETX)
+			if i == -1 {
+				break
+			}
+
+			cmd, err := Decode(pending[:i+1])
+			pending = pending[i+1:]
+			if err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.commands = append(s.commands, cmd)
+			handler, ok := s.handlers[cmd.Keyword]
+			s.mu.Unlock()
+
+			events := []Event{{Keyword: cmd.Keyword, Type: TypeResponse, InvokeID: cmd.InvokeID, Segments: []string{"0", "M00000"}}}
+			if ok {
+				events = handler(cmd)
+			}
+
+			s.mu.Lock()
+			for _, event := range events {
+				if _, err := conn.Write(Encode(event)); err != nil {
+					break
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Encode encodes the event into the wire format.
+func Encode(event Event) []byte {
+	buf := bytes.NewBuffer(nil)
+
+	buf.WriteString(fmt.Sprintf("%-20s", event.Keyword))
+	buf.WriteByte(event.Type)
+	buf.WriteString(fmt.Sprintf("%-20s", "Agent server"))
+	buf.WriteString(fmt.Sprintf("%-6d", 2570))
+	buf.WriteString(fmt.Sprintf("%-4d", event.InvokeID))
+	buf.WriteString(fmt.Sprintf("%-4d", len(event.Segments)))
+	for _, segment := range event.Segments {
+		buf.WriteByte(RS)
+		buf.WriteString(segment)
+	}
+
+	if event.Incomplete {
+		buf.WriteByte(ETB)
+	} else {
+		buf.WriteByte(ETX)
+	}
+
+	return buf.Bytes()
+}
+
+// Decode decodes the command from the wire format.
+func Decode(raw []byte) (Command, error) {
+	if len(raw) < 56 {
+		return Command{}, fmt.Errorf("command is too short: %d bytes", len(raw))
+	}
+
+	invokeID, err := strconv.Atoi(strings.TrimSpace(string(raw[47:51])))
+	if err != nil {
+		return Command{}, fmt.Errorf("cannot parse invoke id: %w", err)
+	}
+
+	cmd := Command{
+		Keyword:  strings.TrimSpace(string(raw[:20])),
+		Client:   strings.TrimSpace(string(raw[21:41])),
+		InvokeID: uint32(invokeID),
+	}
+
+	if len(raw) > 56 {
+		segments := strings.TrimSuffix(string(raw[56:]), string(ETX))
+		cmd.Segments = strings.Split(segments, string(RS))
+	}
+
+	return cmd, nil
+}
+
+// newCertificate generates self-signed certificate for the loopback interface.
+func newCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"apctest"}},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package apctest_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/L11R/go-apc"
+	"github.com/L11R/go-apc/apctest"
+)
+
+func TestServer(t *testing.T) {
+	srv := apctest.NewServer()
+	defer srv.Close()
+
+	srv.Respond("AGTListJobs", []string{"O,outbound1,A", "I,inbound1,I"})
+	srv.Fail("AGTAttachJob", "E28804")
+
+	c, err := apc.NewClient(srv.Addr, apc.WithTlsSkipVerify())
+	if err != nil {
+		t.Fatalf("apc.NewClient() error = %v", err)
+	}
+	defer c.Stop()
+
+	go func() {
+		_ = c.Start()
+	}()
+
+	jobs, err := c.ListJobs(context.Background(), apc.JobTypeAll)
+	if err != nil {
+		t.Fatalf("c.ListJobs() error = %v", err)
+	}
+
+	want := []apc.Job{
+		{Type: apc.JobTypeOutbound, Name: "outbound1", Status: apc.StatusTypeActive},
+		{Type: apc.JobTypeInbound, Name: "inbound1", Status: apc.StatusTypeInactive},
+	}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Errorf("c.ListJobs() = %v, want %v", jobs, want)
+	}
+
+	if err := c.AttachJob(context.Background(), "outbound1"); !errors.Is(err, apc.ErrInvalidJob) {
+		t.Errorf("c.AttachJob() error = %v, want %v", err, apc.ErrInvalidJob)
+	}
+
+	if err := c.EchoOn(context.Background()); err != nil {
+		t.Errorf("c.EchoOn() error = %v", err)
+	}
+
+	commands := srv.Commands()
+	if len(commands) != 3 || commands[2].Keyword != "AGTEchoOn" {
+		t.Errorf("srv.Commands() = %v, want 3 commands ending with AGTEchoOn", commands)
+	}
+}
+
+func TestServer_Notify(t *testing.T) {
+	srv := apctest.NewServer()
+	defer srv.Close()
+
+	c, err := apc.NewClient(srv.Addr, apc.WithTlsSkipVerify())
+	if err != nil {
+		t.Fatalf("apc.NewClient() error = %v", err)
+	}
+	defer c.Stop()
+
+	go func() {
+		_ = c.Start()
+	}()
+
+	notifications := c.Notifications(context.Background())
+
+	if err := srv.Notify("AGTCallNotify", []string{"JOHN DOE", "OUTBOUND", "ACCTNUM,12345"}); err != nil {
+		t.Fatalf("srv.Notify() error = %v", err)
+	}
+
+	n := <-notifications
+	if n.Type != apc.NotificationTypeCallNotify {
+		t.Fatalf("n.Type = %v, want %v", n.Type, apc.NotificationTypeCallNotify)
+	}
+
+	if got := n.Payload.(*apc.CallNotify).KeyValue; got != "12345" {
+		t.Errorf("KeyValue = %v, want 12345", got)
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -33,71 +32,73 @@ func main() {
 		panic(err)
 	}
 
+	simple := client.Simple()
+
 	shutdown := make(chan error)
 	go func(shutdown chan<- error) {
 		shutdown <- client.Start()
 	}(shutdown)
 
-	if err := client.Logon(context.Background(), agentName, password); err != nil {
+	if err := simple.Logon(agentName, password); err != nil {
 		panic(err)
 	}
 
 	defer func() {
-		if err := client.Logoff(context.Background()); err != nil {
+		if err := simple.Logoff(); err != nil {
 			log.Println(err)
 		}
 	}()
 
-	if err := client.ReserveHeadset(context.Background(), headsetID); err != nil {
+	if err := simple.ReserveHeadset(headsetID); err != nil {
 		panic(err)
 	}
 	defer func() {
-		if err := client.FreeHeadset(context.Background()); err != nil {
+		if err := simple.FreeHeadset(); err != nil {
 			log.Println(err)
 		}
 	}()
 
-	if err := client.ConnectHeadset(context.Background()); err != nil {
+	if err := simple.ConnectHeadset(); err != nil {
 		panic(err)
 	}
 	defer func() {
-		if err := client.DisconnectHeadset(context.Background()); err != nil {
+		if err := simple.DisconnectHeadset(); err != nil {
 			log.Println(err)
 		}
 	}()
 
-	if err := client.AttachJob(context.Background(), jobName); err != nil {
+	if err := simple.AttachJob(jobName); err != nil {
 		panic(err)
 	}
 	defer func() {
-		if err := client.DetachJob(context.Background()); err != nil {
+		if err := simple.DetachJob(); err != nil {
 			log.Println(err)
 		}
 	}()
 
-	keys, err := client.ListState(context.Background())
+	keys, err := simple.ListState()
 	if err != nil {
 		panic(err)
 	}
 	_ = keys
 
-	if err := client.SetDataField(context.Background(), apc.ListTypeOutbound, "DEBT_ID"); err != nil {
+	if err := simple.SetDataField(apc.ListTypeOutbound, "DEBT_ID"); err != nil {
 		panic(err)
 	}
-	if err := client.SetDataField(context.Background(), apc.ListTypeOutbound, "CURPHONE"); err != nil {
+	if err := simple.SetDataField(apc.ListTypeOutbound, "CURPHONE"); err != nil {
 		panic(err)
 	}
 
-	if err := client.AvailWork(context.Background()); err != nil {
+	if err := simple.AvailWork(); err != nil {
 		panic(err)
 	}
 	defer func() {
-		if err := client.NoFurtherWork(context.Background()); err != nil {
+		if err := simple.NoFurtherWork(); err != nil {
 			log.Println(err)
 		}
 	}()
 
-	if err := client.ReadyNextItem(context.Background()); err != nil {
+	if err := simple.ReadyNextItem(); err != nil {
 		log.Println(err)
 	}
 
@@ -111,14 +112,14 @@ func main() {
 			return
 		case <-shutdown:
 			return
-		case notification, ok := <-client.Notifications(context.Background()):
+		case notification, ok := <-simple.Notifications():
 			if !ok {
 				fmt.Println("notification channel closed!")
 				return
 			}
 
 			if notification.Type == apc.NotificationTypeCallNotify {
-				for k, v := range notification.Payload.(map[string]string) {
+				for k, v := range notification.Payload.(*apc.CallNotify).Fields {
 					if k != "CURPHONE" {
 						continue
 					}
@@ -129,7 +130,7 @@ func main() {
 						break
 					}
 
-					field, err := client.ReadField(context.Background(), apc.ListTypeOutbound, "PHONE_ID"+strconv.Itoa(id))
+					field, err := simple.ReadField(apc.ListTypeOutbound, "PHONE_ID"+strconv.Itoa(id))
 					if err != nil {
 						log.Println(err)
 						break
@@ -140,15 +141,15 @@ func main() {
 			}
 
 			if notification.Type == apc.NotificationTypeAutoReleaseLine {
-				if err := client.ReleaseLine(context.Background()); err != nil {
+				if err := simple.ReleaseLine(); err != nil {
 					log.Println(err)
 				}
 
-				if err := client.FinishedItem(context.Background(), 22); err != nil {
+				if err := simple.FinishedItem(22); err != nil {
 					log.Println(err)
 				}
 
-				if err := client.ReadyNextItem(context.Background()); err != nil {
+				if err := simple.ReadyNextItem(); err != nil {
 					log.Println(err)
 				}
 			}
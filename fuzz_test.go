@@ -0,0 +1,30 @@
+//go:build go1.18
+// +build go1.18
+
+package apc
+
+import (
+	"testing"
+)
+
+func FuzzDecodeEvent(f *testing.F) {
+	f.Add(rawEvent("AGTSTART", EventTypeNotification, 0, "0", "AGENT_STARTUP"))
+	f.Add(rawEvent("AGTListJobs", EventTypeData, 18, "0", "M00001", "O,outbnd,A"))
+	f.Add(rawEvent("AGTListJobs", EventTypeResponse, 18, "0", "M00000"))
+	f.Add(rawEvent("AGTAttachJob", EventTypeResponse, 9999, "1", "E28804"))
+	f.Add(rawEvent("AGTEchoOn", EventTypeResponse, 1))
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		event, err := decodeEvent(raw)
+		if err != nil {
+			if !IsDecodingError(err) {
+				t.Errorf("decodeEvent() error = %v, want decoding error", err)
+			}
+			return
+		}
+
+		if len(event.Keyword) > 20 {
+			t.Errorf("len(event.Keyword) = %v, want <= 20", len(event.Keyword))
+		}
+	})
+}
@@ -1,11 +1,18 @@
 module github.com/L11R/go-apc
 
-go 1.13
+go 1.19
 
 require (
 	github.com/L11R/apc-tls v0.0.0-20201219155617-7bb29a574c26
-	github.com/refraction-networking/utls v1.2.2 // indirect
 	go.uber.org/atomic v1.11.0
 	go.uber.org/zap v1.27.0
+	golang.org/x/net v0.25.0
 	golang.org/x/text v0.16.0
 )
+
+require (
+	github.com/refraction-networking/utls v1.2.2 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/crypto v0.23.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+)
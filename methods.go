@@ -2,9 +2,16 @@ package apc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/atomic"
 )
 
 type arg struct {
@@ -19,24 +26,86 @@ func newArg(key, value string) arg {
 	}
 }
 
-func newRequest(ctx context.Context) *request {
-	// Add cancellation context to parent one
-	ctx, cancel := context.WithCancel(ctx)
+// requestEventBuffer is the size of the request event channel.
+const requestEventBuffer = 16
+
+func newRequest(ctx context.Context, timeout time.Duration) *request {
+	// Add cancellation context to parent one, it's limited by timeout if any
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
 
 	// Create dedicated event channel for this request
 	return &request{
 		context: ctx,
 		cancel:  cancel,
-		// Usually one request needs two events: data and response
-		eventChan: make(chan Event, 2),
+		// Usually one request needs two events: data and response, but list commands could send
+		// a lot of data messages, so the buffer lets the event loop go on while the request is processing them
+		eventChan: make(chan Event, requestEventBuffer),
+		err:       atomic.NewError(nil),
 	}
 }
 
 func (c *Client) invokeCommand(ctx context.Context, keyword string, args ...arg) (*request, uint32, error) {
-	invokeID := c.invokeIDPool.Get()
+	// Wait for the previous command to be processed if commands are serialized
+	if c.serial != nil {
+		select {
+		case c.serial <- struct{}{}:
+		case <-ctx.Done():
+			return nil, 0, fmt.Errorf("waiting for serialized command: %w", ctx.Err())
+		}
+	}
+
+	// Wait for a released invoke id if all of them are in use
+	invokeID, err := c.invokeIDPool.Acquire(ctx)
+	if err != nil {
+		c.releaseSerial()
+		return nil, 0, &tooManyInFlightError{err: err}
+	}
+
+	// Tracing and metrics last until the request is processed
+	trace := c.traceCommand(ctx, keyword, invokeID, len(args))
+	observe := c.observeCommand(keyword)
+	finish := func(err error) {
+		trace(err)
+		observe(err)
+	}
+
+	r, err := c.sendCommand(ctx, keyword, invokeID, args...)
+	if err != nil {
+		finish(err)
+		return nil, invokeID, err
+	}
+	r.finish = finish
+
+	return r, invokeID, nil
+}
+
+// tooManyInFlightError is ErrTooManyInFlight that keeps the reason the invoke id wasn't acquired,
+// so both errors.Is(err, ErrTooManyInFlight) and errors.Is(err, context.DeadlineExceeded) are true.
+type tooManyInFlightError struct {
+	err error
+}
+
+func (e *tooManyInFlightError) Error() string {
+	return ErrTooManyInFlight.Error() + ": " + e.err.Error()
+}
+
+func (e *tooManyInFlightError) Is(target error) bool {
+	return target == ErrTooManyInFlight
+}
+
+func (e *tooManyInFlightError) Unwrap() error {
+	return e.err
+}
 
-	if c.state.Load() != ConnOK {
-		return nil, invokeID, ErrConnectionClosed
+// sendCommand encodes the command, registers its request and writes the command to the connection.
+func (c *Client) sendCommand(ctx context.Context, keyword string, invokeID uint32, args ...arg) (*request, error) {
+	if c.State() != ConnOK {
+		return nil, ErrConnectionClosed
 	}
 
 	fields := map[string]interface{}{
@@ -55,96 +124,234 @@ func (c *Client) invokeCommand(ctx context.Context, keyword string, args ...arg)
 	}
 	fields["segments"] = flatArgs
 
+	// Transform arguments to the server charset before framing
+	segments, err := c.encodeArgs(args)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode command: %w", err)
+	}
+
 	// Encode command
-	b, err := encodeCommand(keyword, invokeID, flatArgs...)
+	clientName := c.opts.ClientName
+	if clientName == "" {
+		clientName = defaultClientName
+	}
+
+	b, err := encodeCommand(keyword, clientName, c.opts.ProcessID, invokeID, segments...)
 	if err != nil {
-		return nil, invokeID, fmt.Errorf("cannot encode command: %w", err)
+		return nil, fmt.Errorf("cannot encode command: %w", err)
 	}
 	c.logger.log(newLogEntry(LogLevelDebug, "Command has encoded.", map[string]interface{}{"raw": string(b)}))
 
 	// Create the request and place it into the requests map;
 	// it should be done BEFORE writing a command into connection to avoid the situation while server responds
 	// so quickly that events being just skipped before processing goroutine even started
-	r := newRequest(ctx)
+	r := newRequest(ctx, c.opts.CommandTimeout)
+	r.maxPending = c.opts.MaxPending
 	c.mu.Lock()
 	c.requests[invokeID] = r
 	c.mu.Unlock()
 
 	// Write command to connection
-	if _, err := c.conn.Write(b); err != nil {
-		return nil, invokeID, fmt.Errorf("cannot write command: %w", err)
+	c.connMu.RLock()
+	conn := c.conn
+	c.connMu.RUnlock()
+	if _, err := conn.Write(b); err != nil {
+		return nil, fmt.Errorf("cannot write command: %w", err)
 	}
 
 	c.logger.log(newLogEntry(LogLevelInfo, "Command has sent.", fields))
 
-	return r, invokeID, nil
+	if c.opts.TraceHook != nil {
+		c.opts.TraceHook(keyword, invokeID)
+	}
+
+	return r, nil
+}
+
+// encodeArgs returns argument values transformed by the encoder passed with WithEncoder.
+func (c *Client) encodeArgs(args []arg) ([]string, error) {
+	segments := make([]string, 0, len(args))
+	if c.opts.Encoder == nil {
+		for _, arg := range args {
+			segments = append(segments, arg.value)
+		}
+		return segments, nil
+	}
+
+	c.encoderMu.Lock()
+	defer c.encoderMu.Unlock()
+
+	for _, arg := range args {
+		value, err := c.opts.Encoder.String(arg.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode %s argument: %w", arg.key, err)
+		}
+		segments = append(segments, value)
+	}
+
+	return segments, nil
+}
+
+// simpleCommand invokes the command, waits for its response and destroys it; data segments are returned.
+func (c *Client) simpleCommand(ctx context.Context, keyword string, args ...arg) ([]string, error) {
+	r, invokeID, err := c.invokeCommand(ctx, keyword, args...)
+	defer c.destroyCommand(invokeID)
+	if err != nil {
+		return nil, fmt.Errorf("error while executing %s command: %w", keyword, err)
+	}
+
+	return processRequest(r)
+}
+
+// Execute sends the command with the passed keyword and arguments, e.g. the one which isn't wrapped by the Client yet,
+// and returns raw data segments of the response including the message codes (M00001). Server errors are returned
+// as AvayaError, the keyword longer than 20 bytes is rejected with ErrInvalidKeyword.
+func (c *Client) Execute(ctx context.Context, keyword string, args ...string) ([]string, error) {
+	if keyword == "" || len(keyword) > 20 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKeyword, keyword)
+	}
+
+	commandArgs := make([]arg, 0, len(args))
+	for i, value := range args {
+		commandArgs = append(commandArgs, newArg("arg"+strconv.Itoa(i), value))
+	}
+
+	return c.simpleCommand(ctx, keyword, commandArgs...)
 }
 
 func (c *Client) destroyCommand(invokeID uint32) {
+	// invokeCommand has failed to get invoke id
+	if invokeID == 0 {
+		return
+	}
+
 	c.mu.RLock()
-	_, ok := c.requests[invokeID]
+	r, ok := c.requests[invokeID]
 	c.mu.RUnlock()
 
 	// in case of executeCommand func returned an error just release invoke id from pool
 	if !ok {
 		c.invokeIDPool.Release(invokeID)
+		c.releaseSerial()
 		return
 	}
 
-	// Delete request from pool
+	// Delete request from pool and release its context, so the timer of the command timeout is stopped
 	c.mu.Lock()
 	delete(c.requests, invokeID)
 	c.mu.Unlock()
+	r.cancel()
 
 	// Finally release invoke ID
 	c.invokeIDPool.Release(invokeID)
+	c.releaseSerial()
+}
+
+// releaseSerial lets the next serialized command go.
+func (c *Client) releaseSerial() {
+	if c.serial != nil {
+		<-c.serial
+	}
 }
 
 func (c *Client) Logon(ctx context.Context, agentName string, password string) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTLogon", newArg("agent_name", agentName), newArg("password", password), newArg("version", "GOLANG_0.0.3"))
+	_, err := c.LogonWithResult(ctx, agentName, password)
+	return err
+}
+
+// Session describes the agent session established by LogonWithResult.
+type Session struct {
+	// AgentName is the name the agent logged on with
+	AgentName string
+	// Version is Agent API version reported to the server
+	Version string
+	// Data holds data messages of the response, Agent API 5.2 guide doesn't define any,
+	// but some servers send session details this way
+	Data []string
+	// Response holds segments of the successful response following M00000 code, if any
+	Response []string
+}
+
+// LogonWithResult is like Logon, but it returns the established session.
+func (c *Client) LogonWithResult(ctx context.Context, agentName string, password string) (*Session, error) {
+	version := c.opts.ProtocolVersion
+	if version == "" {
+		version = "GOLANG_" + Version
+	}
+
+	r, invokeID, err := c.invokeCommand(ctx, cmdLogon, newArg("agent_name", agentName), newArg("password", password), newArg("version", version))
 	defer c.destroyCommand(invokeID)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTLogon command: %w", err)
+		return nil, fmt.Errorf("error while executing %s command: %w", cmdLogon, err)
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	session := &Session{
+		AgentName: agentName,
+		Version:   version,
 	}
 
-	return nil
-}
-
-func (c *Client) ReserveHeadset(ctx context.Context, headsetID int) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTReserveHeadset", newArg("headset_id", strconv.Itoa(headsetID)))
-	defer c.destroyCommand(invokeID)
+	rawSegments, response, err := processRequestWithResponse(r)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTReserveHeadset command: %w", err)
+		return nil, err
+	}
+	c.agentName.Store(agentName)
+	session.Response = response
+
+	for _, segment := range rawSegments {
+		if segment != "M00001" {
+			session.Data = append(session.Data, segment)
+		}
 	}
 
-	if _, err := processRequest(r); err != nil {
+	return session, nil
+}
+
+func (c *Client) ReserveHeadset(ctx context.Context, headsetID int) error {
+	if _, err := c.simpleCommand(ctx, cmdReserveHeadset, newArg("headset_id", strconv.Itoa(headsetID))); err != nil {
 		return err
 	}
 
+	c.reservedHeadset.Store(int64(headsetID))
+
 	return nil
 }
 
-func (c *Client) ConnectHeadset(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTConnHeadset")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTConnHeadset command: %w", err)
+// ErrHeadsetMismatch is returned by EnsureHeadsetReserved when the agent has reserved another headset.
+var ErrHeadsetMismatch = errors.New("another headset is reserved")
+
+// EnsureHeadsetReserved is like ReserveHeadset, but it checks the headset state by HeadsetStatus first
+// and treats the headset reserved or connected by this agent (e.g. before the restart) as success.
+// If the server reports the ID of the reserved headset and it differs from the passed one,
+// an error matching ErrHeadsetMismatch is returned; servers that don't report the ID are trusted.
+// If the server doesn't support HeadsetStatus, it falls back to ReserveHeadset.
+func (c *Client) EnsureHeadsetReserved(ctx context.Context, headsetID int) error {
+	state, reservedID, err := c.headsetStatus(ctx)
+	var avayaErr AvayaError
+	if err != nil && !errors.As(err, &avayaErr) {
+		return fmt.Errorf("cannot get headset status: %w", err)
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	if state == HeadsetStateReserved || state == HeadsetStateConnected {
+		if reservedID != 0 && reservedID != headsetID {
+			return fmt.Errorf("%w: %d, want %d", ErrHeadsetMismatch, reservedID, headsetID)
+		}
+
+		c.reservedHeadset.Store(int64(headsetID))
+		return nil
 	}
 
-	return nil
+	return c.ReserveHeadset(ctx, headsetID)
+}
+
+func (c *Client) ConnectHeadset(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdConnHeadset)
+	return err
 }
 
 type JobType byte
 
 const (
+	JobTypeUnknown  JobType = 0
 	JobTypeAll      JobType = 'A'
 	JobTypeBlend    JobType = 'B'
 	JobTypeOutbound JobType = 'O'
@@ -152,6 +359,20 @@ const (
 	JobTypeManaged  JobType = 'M'
 )
 
+// parseJobType maps the type field of AGTListJobs data message to JobType.
+func parseJobType(s string) JobType {
+	if len(s) != 1 {
+		return JobTypeUnknown
+	}
+
+	switch jobType := JobType(s[0]); jobType {
+	case JobTypeBlend, JobTypeOutbound, JobTypeInbound, JobTypeManaged:
+		return jobType
+	default:
+		return JobTypeUnknown
+	}
+}
+
 type Job struct {
 	Type   JobType
 	Name   string
@@ -160,19 +381,30 @@ type Job struct {
 
 type StatusType byte
 
+// Agent API guide documents only inactive and active job statuses, anything else is reported
+// as StatusTypeUnknown instead of the raw byte.
 const (
+	StatusTypeUnknown  StatusType = 0
 	StatusTypeInactive StatusType = 'I'
 	StatusTypeActive   StatusType = 'A'
 )
 
-func (c *Client) ListJobs(ctx context.Context, jobType JobType) ([]Job, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTListJobs", newArg("job_type", string([]byte{byte(jobType)})))
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTListJobs command: %w", err)
+// parseStatusType maps the status field of AGTListJobs data message to StatusType.
+func parseStatusType(s string) StatusType {
+	if len(s) != 1 {
+		return StatusTypeUnknown
 	}
 
-	rawSegments, err := processRequest(r)
+	switch status := StatusType(s[0]); status {
+	case StatusTypeInactive, StatusTypeActive:
+		return status
+	default:
+		return StatusTypeUnknown
+	}
+}
+
+func (c *Client) ListJobs(ctx context.Context, jobType JobType) ([]Job, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListJobs, newArg("job_type", string([]byte{byte(jobType)})))
 	if err != nil {
 		return nil, err
 	}
@@ -180,11 +412,12 @@ func (c *Client) ListJobs(ctx context.Context, jobType JobType) ([]Job, error) {
 	jobs := make([]Job, 0, len(rawSegments))
 	for _, segment := range rawSegments {
 		jobParts := strings.Split(segment, ",")
-		if len(jobParts) == 3 {
+		// Job without name cannot be attached, so skip it
+		if len(jobParts) == 3 && jobParts[1] != "" {
 			jobs = append(jobs, Job{
-				Type:   JobType(jobParts[0][0]),
+				Type:   parseJobType(jobParts[0]),
 				Name:   jobParts[1],
-				Status: StatusType(jobParts[2][0]),
+				Status: parseStatusType(jobParts[2]),
 			})
 		}
 	}
@@ -192,14 +425,29 @@ func (c *Client) ListJobs(ctx context.Context, jobType JobType) ([]Job, error) {
 	return jobs, nil
 }
 
-func (c *Client) ListCallLists(ctx context.Context) ([]string, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTListCallLists")
-	defer c.destroyCommand(invokeID)
+// ListActiveJobs sends AGTListJobs command and returns only active jobs of the passed type.
+// Server cannot filter jobs by status, so they are filtered on the client side.
+func (c *Client) ListActiveJobs(ctx context.Context, jobType JobType) ([]Job, error) {
+	jobs, err := c.ListJobs(ctx, jobType)
 	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTListCallLists command: %w", err)
+		return nil, err
 	}
 
-	rawSegments, err := processRequest(r)
+	active := jobs[:0]
+	for _, job := range jobs {
+		if job.Status == StatusTypeActive {
+			active = append(active, job)
+		}
+	}
+
+	return active, nil
+}
+
+// ListCallLists returns raw data segments of AGTListCallLists command.
+//
+// Deprecated: use CallLists instead.
+func (c *Client) ListCallLists(ctx context.Context) ([]string, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListCallLists)
 	if err != nil {
 		return nil, err
 	}
@@ -212,14 +460,44 @@ func (c *Client) ListCallLists(ctx context.Context) ([]string, error) {
 	return callLists, nil
 }
 
-func (c *Client) ListCallFields(ctx context.Context, listName string) ([]string, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTListCallFields", newArg("list_name", listName))
-	defer c.destroyCommand(invokeID)
+// CallList is the calling list on the system, the segment format is <ListName>[,<Flag>...].
+type CallList struct {
+	Name string
+	// Flags are the status flags following the list name; Agent API 5.2 guide lists the names only,
+	// so the flags depend on the server version and are nil if the server doesn't send them
+	Flags []string
+}
+
+// CallLists sends AGTListCallLists command and returns all calling lists on the system.
+func (c *Client) CallLists(ctx context.Context) ([]CallList, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListCallLists)
 	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTListCallFields command: %w", err)
+		return nil, err
 	}
 
-	rawSegments, err := processRequest(r)
+	callLists := make([]CallList, 0, len(rawSegments))
+	for _, segment := range rawSegments {
+		// Skip data message code
+		if segment == "M00001" || segment == "" {
+			continue
+		}
+
+		callListParts := strings.Split(segment, ",")
+		callList := CallList{Name: callListParts[0]}
+		if len(callListParts) > 1 {
+			callList.Flags = callListParts[1:]
+		}
+		callLists = append(callLists, callList)
+	}
+
+	return callLists, nil
+}
+
+// ListCallFields returns raw data segments of AGTListCallFields command.
+//
+// Deprecated: use CallFields instead.
+func (c *Client) ListCallFields(ctx context.Context, listName string) ([]string, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListCallFields, newArg("list_name", listName))
 	if err != nil {
 		return nil, err
 	}
@@ -232,17 +510,106 @@ func (c *Client) ListCallFields(ctx context.Context, listName string) ([]string,
 	return callFields, nil
 }
 
-func (c *Client) AttachJob(ctx context.Context, jobName string) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTAttachJob", newArg("job_name", jobName))
-	defer c.destroyCommand(invokeID)
+type CallField struct {
+	Name   string
+	Length int
+	Type   FieldType
+}
+
+// CallFields sends AGTListCallFields command and returns the fields of the passed calling list.
+func (c *Client) CallFields(ctx context.Context, listName string) ([]CallField, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListCallFields, newArg("list_name", listName))
 	if err != nil {
-		return fmt.Errorf("error while executing AGTAttachJob command: %w", err)
+		return nil, err
+	}
+
+	callFields := make([]CallField, 0, len(rawSegments))
+	for _, segment := range rawSegments {
+		// <FieldName>,<FieldLength>,<FieldType>,F
+		callFieldParts := strings.Split(segment, ",")
+		if len(callFieldParts) == 4 {
+			length, err := strconv.Atoi(callFieldParts[1])
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert field length: %w", err)
+			}
+
+			callFields = append(callFields, CallField{
+				Name:   callFieldParts[0],
+				Length: length,
+				Type:   FieldType(callFieldParts[2]),
+			})
+		}
+	}
+
+	return callFields, nil
+}
+
+func (c *Client) AttachJob(ctx context.Context, jobName string) error {
+	c.resetCompletionCodes()
+	c.resetDataFields()
+
+	if _, err := c.simpleCommand(ctx, cmdAttachJob, newArg("job_name", jobName)); err != nil {
+		return err
+	}
+
+	c.attachedJob.Store(jobName)
+
+	return nil
+}
+
+// WorkClass is the agent type, it must match the type of the attached job to get calls.
+type WorkClass byte
+
+const (
+	WorkClassInbound        WorkClass = 'I'
+	WorkClassOutbound       WorkClass = 'O'
+	WorkClassBlend          WorkClass = 'B'
+	WorkClassPersonToPerson WorkClass = 'P'
+	WorkClassManaged        WorkClass = 'M'
+)
+
+// SetWorkClass sends AGTSetWorkClass command, it sets the agent type; server defaults it to WorkClassOutbound.
+// The type carries from job to job until reset, it cannot be changed while the agent is available for work.
+func (c *Client) SetWorkClass(ctx context.Context, class WorkClass) error {
+	_, err := c.simpleCommand(ctx, cmdSetWorkClass, newArg("class_id", string([]byte{byte(class)})))
+	return err
+}
+
+// SetUnits sends AGTSetUnit command, it selects Unit IDs of the attached Unit Work List job.
+// Selecting several units requires multi unit selection feature enabled on the server.
+func (c *Client) SetUnits(ctx context.Context, units ...string) error {
+	_, err := c.simpleCommand(ctx, cmdSetUnit, newArg("unit_id", strings.Join(units, "|")))
+	return err
+}
+
+// JobAttachOptions are applied by AttachJobWithOptions, zero values are skipped.
+type JobAttachOptions struct {
+	// WorkClass is set by AGTSetWorkClass before attaching the job
+	WorkClass WorkClass
+	// Units are selected by AGTSetUnit after attaching the job, Unit Work List jobs only;
+	// more than one unit is supported by servers with multi unit selection feature
+	Units []string
+}
+
+// AttachJobWithOptions attaches the job and applies the options. AGTAttachJob accepts the job name only
+// (Agent API 5.2), so options are applied with own setup commands and any of them could fail separately.
+func (c *Client) AttachJobWithOptions(ctx context.Context, jobName string, opts JobAttachOptions) error {
+	if opts.WorkClass != 0 {
+		if err := c.SetWorkClass(ctx, opts.WorkClass); err != nil {
+			return err
+		}
 	}
 
-	if _, err := processRequest(r); err != nil {
+	if err := c.AttachJob(ctx, jobName); err != nil {
 		return err
 	}
 
+	if len(opts.Units) > 0 {
+		if err := c.SetUnits(ctx, opts.Units...); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -254,263 +621,653 @@ const (
 )
 
 type DataField struct {
-	Name string
+	Name   string
+	Length int
+	Type   FieldType
 }
 
+// ListDataFields sends AGTListDataFields command and returns data fields of the passed calling list.
+// Field lengths are stored to validate WriteField calls until the job is detached.
 func (c *Client) ListDataFields(ctx context.Context, listType ListType) ([]DataField, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTListDataFields", newArg("list_type", string([]byte{byte(listType)})))
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTListDataFields command: %w", err)
-	}
-
-	rawSegments, err := processRequest(r)
+	rawSegments, err := c.simpleCommand(ctx, cmdListDataFields, newArg("list_type", string([]byte{byte(listType)})))
 	if err != nil {
 		return nil, err
 	}
 
 	dataFields := make([]DataField, 0, len(rawSegments))
+	lengths := make(map[string]int, len(rawSegments))
 	for _, segment := range rawSegments {
+		// <FieldName>,<FieldLength>,<FieldType>,F
 		dataFieldParts := strings.Split(segment, ",")
 		if len(dataFieldParts) == 4 {
+			length, err := strconv.Atoi(dataFieldParts[1])
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert field length: %w", err)
+			}
+
 			dataFields = append(dataFields, DataField{
-				Name: dataFieldParts[0],
+				Name:   dataFieldParts[0],
+				Length: length,
+				Type:   FieldType(dataFieldParts[2]),
 			})
+			lengths[dataFieldParts[0]] = length
 		}
 	}
 
+	c.dataFieldsMu.Lock()
+	if c.dataFields == nil {
+		c.dataFields = make(map[ListType]map[string]int)
+	}
+	c.dataFields[listType] = lengths
+	c.dataFieldsMu.Unlock()
+
 	return dataFields, nil
 }
 
+// resetDataFields forgets stored data fields, because each job has own calling list.
+func (c *Client) resetDataFields() {
+	c.dataFieldsMu.Lock()
+	c.dataFields = nil
+	c.dataFieldsMu.Unlock()
+}
+
 func (c *Client) SetNotifyKeyField(ctx context.Context, listType ListType, fieldName string) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTSetNotifyKeyField", newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
-	defer c.destroyCommand(invokeID)
+	_, err := c.simpleCommand(ctx, cmdSetNotifyKeyField, newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
+	return err
+}
+
+// ClearNotifyKeyField sends AGTReqNotKeyField command, it clears the key field set by SetNotifyKeyField,
+// so call notifications don't carry the key until the next SetNotifyKeyField call.
+// Note that it isn't necessary to change the key: each SetNotifyKeyField call replaces it and DetachJob clears it.
+func (c *Client) ClearNotifyKeyField(ctx context.Context, listType ListType) error {
+	_, err := c.simpleCommand(ctx, cmdReqNotKeyField, newArg("list_type", string([]byte{byte(listType)})))
+	return err
+}
+
+func (c *Client) SetDataField(ctx context.Context, listType ListType, fieldName string) error {
+	_, err := c.simpleCommand(ctx, cmdSetDataField, newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
+	return err
+}
+
+func (c *Client) AvailWork(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdAvailWork)
+	return err
+}
+
+func (c *Client) ReadyNextItem(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdReadyNextItem)
+	return err
+}
+
+// ManagedCall sends AGTManagedCall command, it places the call to the customer whose record is being previewed
+// (see NotificationTypePreviewRecord) before the preview period elapses. To skip the record use FinishedItem instead.
+// If the attached job is not a Managed Dialing job, ErrNotManagedJob is returned. The agent must also be available
+// for work (ErrNotAvailableForWork) and be previewing a record (ErrNoCustomerRecord).
+func (c *Client) ManagedCall(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdManagedCall)
+	return err
+}
+
+func (c *Client) ListKeys(ctx context.Context) ([]string, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListKeys)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTSetNotifyKeyField command: %w", err)
+		return nil, err
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	keys := make([]string, 0, len(rawSegments))
+	for _, segment := range rawSegments {
+		keys = append(keys, segment)
 	}
 
-	return nil
+	return keys, nil
 }
 
-func (c *Client) SetDataField(ctx context.Context, listType ListType, fieldName string) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTSetDataField", newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
-	defer c.destroyCommand(invokeID)
+// Key is the agent key of the attached job, per Agent API 5.2 guide each key binds the completion code.
+type Key struct {
+	// Position is 1-based position of the key in AGTListKeys response, i.e. the number of the key in UI
+	Position int
+	// Code is the completion code of the key, it's passed to ReleaseLine and FinishedItem
+	Code int
+	// Description is the action of the key from Compcode.cfg file
+	Description string
+	// ScriptLabel is the telephone script label of the key from Telephny.spt file
+	ScriptLabel string
+	// Enabled is false for unused keys, they don't have the completion code
+	Enabled bool
+}
+
+// ListKeyBindings sends AGTListKeys command and returns parsed keys of the attached job, including unused ones.
+// Use ListKeys to get raw data segments.
+func (c *Client) ListKeyBindings(ctx context.Context) ([]Key, error) {
+	rawSegments, err := c.ListKeys(ctx)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTSetDataField command: %w", err)
+		return nil, err
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	keys := make([]Key, 0, len(rawSegments))
+	for _, segment := range rawSegments {
+		// Skip message codes of data messages
+		if segment == "M00001" {
+			continue
+		}
+
+		key, err := parseKey(len(keys)+1, segment)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
 	}
 
-	return nil
+	return keys, nil
 }
 
-func (c *Client) AvailWork(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTAvailWork")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTAvailWork command: %w", err)
+// parseKey parses <CompCode>,<Description>,<ScriptLabel> segment of AGTListKeys data message.
+func parseKey(position int, segment string) (Key, error) {
+	parts := strings.SplitN(segment, ",", 3)
+	if len(parts) != 3 {
+		return Key{}, fmt.Errorf("invalid key segment: %q", segment)
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	key := Key{
+		Position:    position,
+		Description: parts[1],
+		ScriptLabel: parts[2],
 	}
 
-	return nil
-}
+	// Unused keys have empty code
+	if parts[0] == "" {
+		return key, nil
+	}
 
-func (c *Client) ReadyNextItem(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTReadyNextItem")
-	defer c.destroyCommand(invokeID)
+	code, err := strconv.Atoi(parts[0])
 	if err != nil {
-		return fmt.Errorf("error while executing AGTReadyNextItem command: %w", err)
+		return Key{}, fmt.Errorf("cannot convert completion code: %w", err)
 	}
+	key.Code = code
+	key.Enabled = true
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	return key, nil
+}
+
+func (c *Client) ReleaseLine(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdReleaseLine)
+	return err
+}
+
+// AnswerCall sends AGTAnswerCall command, it answers the alerting inbound call of the agent.
+// If there is no call to answer, ErrNoActiveCall (or other AvayaError) is returned.
+func (c *Client) AnswerCall(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdAnswerCall)
+	return err
+}
+
+// HoldCall sends AGTHoldCall command, it places the customer on hold.
+// If there is no open telephone line (e.g. the call was already released) it returns AvayaError with E28866 code.
+func (c *Client) HoldCall(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdHoldCall)
+	return err
+}
+
+// PlayMessage sends AGTPlayMessage command, it plays the recorded message to the customer on the open telephone line.
+// Message IDs are names of voice messages recorded and provisioned on Proactive Contact system by administrator,
+// the same ones jobs use for inbound and outbound messages, there is no command to list them.
+// If there is no open telephone line it returns AvayaError with E28866 code.
+func (c *Client) PlayMessage(ctx context.Context, messageID string) error {
+	_, err := c.simpleCommand(ctx, cmdPlayMessage, newArg("message_id", messageID))
+	return err
+}
+
+// ErrInvalidDigit is returned by SendDTMF when the digits contain anything except 0-9, * and #.
+var ErrInvalidDigit = errors.New("invalid DTMF digit")
+
+// SendDTMF sends AGTDialDigit command per each digit, it sends DTMF tones on the open telephone line,
+// e.g. to call customer extension or to navigate IVR. Digits are validated before any command is sent.
+// If there is no open telephone line it returns AvayaError with E28866 code.
+func (c *Client) SendDTMF(ctx context.Context, digits string) error {
+	for _, d := range digits {
+		if !strings.ContainsRune("0123456789*#", d) {
+			return fmt.Errorf("%w: %q", ErrInvalidDigit, d)
+		}
+	}
+
+	for _, d := range digits {
+		if err := c.dialDigit(ctx, string(d)); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (c *Client) ListKeys(ctx context.Context) ([]string, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTListKeys")
-	defer c.destroyCommand(invokeID)
+func (c *Client) dialDigit(ctx context.Context, digit string) error {
+	_, err := c.simpleCommand(ctx, cmdDialDigit, newArg("digit", digit))
+	return err
+}
+
+// ReconnectCall sends AGTUnholdCall command, it reconnects the customer placed on hold by HoldCall.
+// If there is no open telephone line (e.g. the call was already released) it returns AvayaError with E28866 code.
+func (c *Client) ReconnectCall(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdUnholdCall)
+	return err
+}
+
+// TransferCall sends AGTTransferCall command, it places the customer on hold and calls the passed extension
+// (or phone number) over a transfer trunk. If the extension is invalid or the transfer cannot be placed
+// it returns AvayaError with E28628 code; E28866 means there is no open telephone line.
+func (c *Client) TransferCall(ctx context.Context, extension string) error {
+	_, err := c.simpleCommand(ctx, cmdTransferCall, newArg("extension", extension))
+	return err
+}
+
+// ConferenceCall sends AGTTransferCall command a second time with no phone number, it establishes the three-way
+// conference call. Call it after TransferCall, when the agent has consulted the person receiving the transfer
+// while the customer is on hold: the customer is brought back and all three parties are connected.
+// E28867 means there is no call off hook, E29950 means the system is configured with CTI, which doesn't support it.
+func (c *Client) ConferenceCall(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdTransferCall)
+	return err
+}
+
+// ManualCall sends AGTManualCall command, it places a manual call to the passed phone number.
+// The agent must be attached to a job and have an open telephone line, otherwise it returns AvayaError
+// with E28866 code; E28843 means the phone number is invalid.
+func (c *Client) ManualCall(ctx context.Context, phoneNumber string) error {
+	_, err := c.simpleCommand(ctx, cmdManualCall, newArg("phone_number", phoneNumber))
+	return err
+}
+
+// PreviewRecord sends AGTPreviewRecord command, it tells the server that the agent is ready to preview the record,
+// the record itself is delivered with NotificationTypePreviewRecord notification. The agent must be attached
+// to a Managed Dialing job (ErrNotManagedJob) and be available for work (ErrNotAvailableForWork).
+//
+// Agent API 5.2 guide describes AGTPreviewRecord as the notification event only, so the command depends on the server version.
+func (c *Client) PreviewRecord(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdPreviewRecord)
+	return err
+}
+
+// DialPreview places the call to the customer whose record is being previewed, it's the same as ManagedCall:
+// Agent API 5.2 guide has no separate command to dial the preview, AGTManagedCall places the call.
+// Errors and the required agent state are the ones of ManagedCall.
+func (c *Client) DialPreview(ctx context.Context) error {
+	return c.ManagedCall(ctx)
+}
+
+// CallbackFormat is the recall format of the current customer record.
+type CallbackFormat struct {
+	// DateFormat is the date format used by the server, e.g. YYYY/MM/DD
+	DateFormat string
+	// Phones is the number of phones available for recall, it's the maximum Callback.PhoneIndex
+	Phones int
+}
+
+// ListCallbackFormat sends AGTListCallbackFmt command and returns the recall format of the current customer record.
+// Recalls are not available on inbound jobs (E28868).
+func (c *Client) ListCallbackFormat(ctx context.Context) (*CallbackFormat, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListCallbackFmt)
 	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTListKeys command: %w", err)
+		return nil, err
+	}
+
+	if len(rawSegments) != 3 || rawSegments[0] != "M00001" {
+		return nil, fmt.Errorf("invalid segment")
 	}
 
-	rawSegments, err := processRequest(r)
+	phones, err := strconv.Atoi(rawSegments[2])
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("cannot convert phones number: %w", err)
 	}
 
-	keys := make([]string, 0, len(rawSegments))
-	for _, segment := range rawSegments {
-		keys = append(keys, segment)
+	return &CallbackFormat{
+		DateFormat: rawSegments[1],
+		Phones:     phones,
+	}, nil
+}
+
+// Layout returns Go time layout of the date format.
+func (f *CallbackFormat) Layout() (string, error) {
+	// Some servers return the sample date instead of the format, e.g. 1999/03/06
+	if strings.ContainsAny(f.DateFormat, "0123456789") {
+		if len(f.DateFormat) == 10 && f.DateFormat[4] == '/' {
+			return "2006/01/02", nil
+		}
+
+		return "", fmt.Errorf("unknown date format: %s", f.DateFormat)
 	}
 
-	return keys, nil
+	layout := strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(f.DateFormat)
+	if strings.ContainsAny(layout, "YMD") {
+		return "", fmt.Errorf("unknown date format: %s", f.DateFormat)
+	}
+
+	return layout, nil
 }
 
-func (c *Client) ReleaseLine(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTReleaseLine")
-	defer c.destroyCommand(invokeID)
+// Callback is the customer recall scheduled by SetCallback.
+type Callback struct {
+	// Time of the recall, it's sent as the date in the server format and the time of day in 24-hour clock (HHMM);
+	// server adjusts it for the customer time zone
+	Time time.Time
+	// PhoneIndex is the index of the phone field to call, 1 is PHONE1, 2 is PHONE2 and so on
+	PhoneIndex int
+	// Name is the customer name field to contact during the recall, it's optional
+	Name string
+	// PhoneNumber is the phone number to call instead of PhoneIndex one, it's optional
+	PhoneNumber string
+}
+
+// SetCallback sends AGTSetCallback command, it schedules the recall of the current customer record.
+// The date format is requested by AGTListCallbackFmt command before.
+// Invalid dates and times are reported by AvayaError with E28831-E28840 codes.
+func (c *Client) SetCallback(ctx context.Context, cb Callback) error {
+	format, err := c.ListCallbackFormat(ctx)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTReleaseLine command: %w", err)
+		return fmt.Errorf("cannot get callback format: %w", err)
 	}
 
-	if _, err := processRequest(r); err != nil {
+	layout, err := format.Layout()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	args := []arg{
+		newArg("date", cb.Time.Format(layout)),
+		newArg("time", cb.Time.Format("1504")),
+		newArg("phone_index", strconv.Itoa(cb.PhoneIndex)),
+	}
+	if cb.Name != "" || cb.PhoneNumber != "" {
+		args = append(args, newArg("recall_name", cb.Name), newArg("recall_number", cb.PhoneNumber))
+	}
+
+	_, err = c.simpleCommand(ctx, cmdSetCallback, args...)
+	return err
 }
 
-func (c *Client) FinishedItem(ctx context.Context, compCode int) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTFinishedItem", newArg("comp_code", strconv.Itoa(compCode)))
-	defer c.destroyCommand(invokeID)
+// ClearCallback sends AGTClearCallback command, it cancels the recall of the current customer record
+// scheduled by SetCallback before.
+// The command is not described by Agent API guide, servers which don't support it return AvayaError.
+func (c *Client) ClearCallback(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdClearCallback)
+	return err
+}
+
+type CompletionCode struct {
+	Code        int
+	Description string
+	ScriptLabel string
+}
+
+// ListCompletionCodes sends AGTListKeys command and returns completion codes of the attached job.
+// Returned codes are stored to validate FinishedItem and SetCompletionCode calls until the job is detached.
+func (c *Client) ListCompletionCodes(ctx context.Context) ([]CompletionCode, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListKeys)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTFinishedItem command: %w", err)
+		return nil, err
+	}
+
+	compCodes := make([]CompletionCode, 0, len(rawSegments))
+	validCodes := make(map[int]struct{}, len(rawSegments))
+	for _, segment := range rawSegments {
+		// <CompCode>,<Description>,<ScriptLabel>; unused keys have empty code
+		compCodeParts := strings.SplitN(segment, ",", 3)
+		if len(compCodeParts) != 3 || compCodeParts[0] == "" {
+			continue
+		}
+
+		code, err := strconv.Atoi(compCodeParts[0])
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert completion code: %w", err)
+		}
+
+		compCodes = append(compCodes, CompletionCode{
+			Code:        code,
+			Description: compCodeParts[1],
+			ScriptLabel: compCodeParts[2],
+		})
+		validCodes[code] = struct{}{}
 	}
 
-	if _, err := processRequest(r); err != nil {
+	c.compCodesMu.Lock()
+	c.compCodes = validCodes
+	c.compCodesMu.Unlock()
+
+	return compCodes, nil
+}
+
+// resetCompletionCodes forgets stored completion codes, because each job has own ones.
+func (c *Client) resetCompletionCodes() {
+	c.compCodesMu.Lock()
+	c.compCodes = nil
+	c.compCodesMu.Unlock()
+}
+
+// FinishedItem checks that the completion code is valid for the attached job and then sends AGTFinishedItem command.
+// Valid codes are requested by ListCompletionCodes once after attaching a job.
+// In case of unknown code it returns an error matching ErrInvalidCompletion without sending the command.
+func (c *Client) FinishedItem(ctx context.Context, compCode int) error {
+	if err := c.checkCompletionCode(ctx, compCode); err != nil {
 		return err
 	}
 
-	return nil
+	return c.FinishedItemUnchecked(ctx, compCode)
 }
 
-func (c *Client) NoFurtherWork(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTNoFurtherWork")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTNoFurtherWork command: %w", err)
+// checkCompletionCode returns an error matching ErrInvalidCompletion if the code is unknown for the attached job.
+func (c *Client) checkCompletionCode(ctx context.Context, compCode int) error {
+	c.compCodesMu.RLock()
+	validCodes := c.compCodes
+	c.compCodesMu.RUnlock()
+
+	if validCodes == nil {
+		if _, err := c.ListCompletionCodes(ctx); err != nil {
+			return fmt.Errorf("cannot list completion codes: %w", err)
+		}
+
+		c.compCodesMu.RLock()
+		validCodes = c.compCodes
+		c.compCodesMu.RUnlock()
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	if _, ok := validCodes[compCode]; !ok {
+		return fmt.Errorf("%w: %d", ErrInvalidCompletion, compCode)
 	}
 
 	return nil
 }
 
-func (c *Client) DetachJob(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTDetachJob")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTDetachJob command: %w", err)
+// SetCompletionCode checks that the completion code is valid for the attached job the same way as FinishedItem does
+// and then sends AGTSetCompCode command, it sets the code of the current customer record without finishing it,
+// e.g. to let a supervisor review the record. The expected order is ReleaseLine, SetCompletionCode and then
+// FinishedItem with the same code, which releases the record.
+func (c *Client) SetCompletionCode(ctx context.Context, compCode int) error {
+	if err := c.checkCompletionCode(ctx, compCode); err != nil {
+		return err
 	}
 
-	if _, err := processRequest(r); err != nil {
+	_, err := c.simpleCommand(ctx, cmdSetCompCode, newArg("comp_code", strconv.Itoa(compCode)))
+	return err
+}
+
+// FinishedItemUnchecked sends AGTFinishedItem command without validating the completion code.
+func (c *Client) FinishedItemUnchecked(ctx context.Context, compCode int) error {
+	_, err := c.simpleCommand(ctx, cmdFinishedItem, newArg("comp_code", strconv.Itoa(compCode)))
+	return err
+}
+
+func (c *Client) NoFurtherWork(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdNoFurtherWork)
+	return err
+}
+
+func (c *Client) DetachJob(ctx context.Context) error {
+	c.resetCompletionCodes()
+	c.resetDataFields()
+
+	if _, err := c.simpleCommand(ctx, cmdDetachJob); err != nil {
 		return err
 	}
 
+	c.attachedJob.Store("")
+
 	return nil
 }
 
 func (c *Client) DisconnectHeadset(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTDisconnHeadset")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTDisconnHeadset command: %w", err)
-	}
+	_, err := c.simpleCommand(ctx, cmdDisconnHeadset)
+	return err
+}
 
-	if _, err := processRequest(r); err != nil {
+func (c *Client) FreeHeadset(ctx context.Context) error {
+	if _, err := c.simpleCommand(ctx, cmdFreeHeadset); err != nil {
 		return err
 	}
 
+	c.reservedHeadset.Store(0)
+
 	return nil
 }
 
-func (c *Client) FreeHeadset(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTFreeHeadset")
-	defer c.destroyCommand(invokeID)
+// HeadsetState is the state of the agent headset.
+type HeadsetState byte
+
+const (
+	HeadsetStateUnknown   HeadsetState = 0
+	HeadsetStateFree      HeadsetState = 'F'
+	HeadsetStateReserved  HeadsetState = 'R'
+	HeadsetStateConnected HeadsetState = 'C'
+)
+
+// HeadsetStatus sends AGTReqHeadset command and returns the state of the agent headset, e.g. to find out
+// that the headset is still reserved or connected by the crashed session before calling ReserveHeadset.
+// The state is expected as the first character of the data message, unknown ones are returned as HeadsetStateUnknown.
+func (c *Client) HeadsetStatus(ctx context.Context) (HeadsetState, error) {
+	state, _, err := c.headsetStatus(ctx)
+	return state, err
+}
+
+// headsetStatus is like HeadsetStatus, but it also returns the headset ID if the server reports it
+// after the state: <State>[,<HeadsetID>]; otherwise the ID is zero.
+func (c *Client) headsetStatus(ctx context.Context) (HeadsetState, int, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdReqHeadset)
 	if err != nil {
-		return fmt.Errorf("error while executing AGTFreeHeadset command: %w", err)
+		return HeadsetStateUnknown, 0, err
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	if len(rawSegments) < 2 || rawSegments[0] != "M00001" || rawSegments[1] == "" {
+		return HeadsetStateUnknown, 0, fmt.Errorf("invalid segment")
 	}
 
-	return nil
+	var headsetID int
+	if parts := strings.SplitN(rawSegments[1], ",", 3); len(parts) > 1 && parts[1] != "" {
+		headsetID, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return HeadsetStateUnknown, 0, fmt.Errorf("cannot convert headset id: %w", err)
+		}
+	}
+
+	switch state := HeadsetState(rawSegments[1][0]); state {
+	case HeadsetStateFree, HeadsetStateReserved, HeadsetStateConnected:
+		return state, headsetID, nil
+	default:
+		return HeadsetStateUnknown, headsetID, nil
+	}
 }
 
 // Logoff sends ATGLogoff command, then Proactive Control server terminates session
 func (c *Client) Logoff(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTLogoff")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTLogoff command: %w", err)
-	}
-
-	if _, err := processRequest(r); err != nil {
+	if _, err := c.simpleCommand(ctx, cmdLogoff); err != nil {
 		return err
 	}
+	c.agentName.Store("")
 
 	return nil
 }
 
-func (c *Client) EchoOn(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTEchoOn")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTEchoOn command: %w", err)
+// ChangePassword sends AGTSetPassword command, it changes the password of the logged on agent.
+// Server enforces the password rules: it's case sensitive, contains 6 or more 7-bit ASCII characters with at least
+// two alphabetic ones, it isn't a variation of the user name and differs from the current one by at least three
+// characters. Violations are reported by AvayaError matching ErrInvalidPassword, and AvayaError matching
+// ErrPasswordChangeDenied is returned if the system doesn't allow changing the password.
+// If the agent isn't logged on by this Client it returns ErrNotLoggedOn without sending the command.
+func (c *Client) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	agentName := c.agentName.Load()
+	if agentName == "" {
+		return ErrNotLoggedOn
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
-	}
+	_, err := c.simpleCommand(ctx, cmdSetPassword, newArg("user_id", agentName), newArg("present_password", oldPassword), newArg("new_password", newPassword))
+	return err
+}
 
-	return nil
+func (c *Client) EchoOn(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdEchoOn)
+	return err
 }
 
 func (c *Client) EchoOff(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTEchoOff")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTEchoOff command: %w", err)
-	}
+	_, err := c.simpleCommand(ctx, cmdEchoOff)
+	return err
+}
 
-	if _, err := processRequest(r); err != nil {
-		return err
-	}
+func (c *Client) LogIoStart(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdLogIoStart)
+	return err
+}
 
-	return nil
+func (c *Client) LogIoStop(ctx context.Context) error {
+	_, err := c.simpleCommand(ctx, cmdLogIoStop)
+	return err
 }
 
-func (c *Client) LogIoStart(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTLogIoStart")
-	defer c.destroyCommand(invokeID)
-	if err != nil {
-		return fmt.Errorf("error while executing AGTLogIoStart command: %w", err)
-	}
+// MaxMessageLength is the maximum length of the message sent by SendMessage,
+// supervisor screen has only one line to display it.
+const MaxMessageLength = 79
 
-	if _, err := processRequest(r); err != nil {
-		return err
+// ErrMessageTooLong is returned by SendMessage when the message exceeds MaxMessageLength characters.
+var ErrMessageTooLong = errors.New("message is too long")
+
+// SendMessage sends AGTSendMessage command, it sends the message to the supervisor screen.
+// There is no recipient argument: according to Agent API 5.2 guide the command has the only data parameter,
+// the message, and the server always displays it to the Proactive Contact supervisor process.
+// Messages to the agent are received with NotificationTypeReceiveMessage notification.
+func (c *Client) SendMessage(ctx context.Context, message string) error {
+	if utf8.RuneCountInString(message) > MaxMessageLength {
+		return ErrMessageTooLong
 	}
 
-	return nil
+	_, err := c.simpleCommand(ctx, cmdSendMessage, newArg("message", message))
+	return err
 }
 
-func (c *Client) LogIoStop(ctx context.Context) error {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTLogIoStop")
-	defer c.destroyCommand(invokeID)
+// GetAppData sends AGTGetAppData command, it returns application-specific data stored against the agent session.
+// If there is no data stored by the passed key, server returns an empty data message and empty string is returned.
+func (c *Client) GetAppData(ctx context.Context, key string) (string, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdGetAppData, newArg("key", key))
 	if err != nil {
-		return fmt.Errorf("error while executing AGTLogIoStop command: %w", err)
+		return "", err
 	}
 
-	if _, err := processRequest(r); err != nil {
-		return err
+	// Key is missing, there is nothing except the message code
+	if len(rawSegments) < 2 || rawSegments[1] == "" {
+		return "", nil
 	}
 
-	return nil
+	if len(rawSegments) != 2 || rawSegments[0] != "M00001" {
+		return "", fmt.Errorf("invalid segment")
+	}
+
+	parts := strings.SplitN(rawSegments[1], ",", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid segment")
+	}
+
+	return parts[1], nil
+}
+
+// SetAppData sends AGTSetAppData command, it stores application-specific data against the agent session.
+func (c *Client) SetAppData(ctx context.Context, key, value string) error {
+	_, err := c.simpleCommand(ctx, cmdSetAppData, newArg("key", key), newArg("value", value))
+	return err
 }
 
 type State struct {
@@ -528,25 +1285,45 @@ const (
 	StateTypeLoggedOn       StateType = "S70004"
 )
 
+// ListState sends AGTListState command and returns the primary (first) state of the agent.
 func (c *Client) ListState(ctx context.Context) (*State, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTListState")
-	defer c.destroyCommand(invokeID)
+	states, err := c.ListStates(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTListState command: %w", err)
+		return nil, err
 	}
 
-	rawSegments, err := processRequest(r)
+	if len(states) == 0 {
+		return nil, fmt.Errorf("invalid segment")
+	}
+
+	return &states[0], nil
+}
+
+// ListStates sends AGTListState command and returns all states of the agent,
+// e.g. the agent could be joined to the job and be ready for a call at the same time.
+func (c *Client) ListStates(ctx context.Context) ([]State, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdListState)
 	if err != nil {
 		return nil, err
 	}
 
-	if rawSegments == nil || len(rawSegments) != 1 {
-		return nil, fmt.Errorf("invalid segment")
+	states := make([]State, 0, len(rawSegments))
+	for _, segment := range rawSegments {
+		state, err := parseState(segment)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
 	}
 
-	parts := strings.Split(rawSegments[0], ",")
-	if len(parts) > 2 {
-		return nil, fmt.Errorf("invalid segment")
+	return states, nil
+}
+
+// parseState parses the state segment: <StateCode>[,<JobName>]
+func parseState(segment string) (State, error) {
+	parts := strings.Split(segment, ",")
+	if len(parts) > 2 || parts[0] == "" {
+		return State{}, fmt.Errorf("invalid segment")
 	}
 
 	var jobName string
@@ -554,7 +1331,7 @@ func (c *Client) ListState(ctx context.Context) (*State, error) {
 		jobName = parts[1]
 	}
 
-	return &State{
+	return State{
 		Type:    StateType(parts[0]),
 		JobName: jobName,
 	}, nil
@@ -571,24 +1348,36 @@ type FieldType string
 
 const (
 	FieldTypeAlphanumeric FieldType = "A"
+	FieldTypeCharacter    FieldType = "C"
 	FieldTypeNumeric      FieldType = "N"
 	FieldTypeDate         FieldType = "D"
+	FieldTypeTime         FieldType = "T"
 	FieldTypeCurrency     FieldType = "$"
 	FieldTypeFutureUse    FieldType = "F"
 )
 
 func (c *Client) ReadField(ctx context.Context, listType ListType, fieldName string) (*Field, error) {
-	r, invokeID, err := c.invokeCommand(ctx, "AGTReadField", newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
-	defer c.destroyCommand(invokeID)
+	rawSegments, err := c.simpleCommand(ctx, cmdReadField, newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
 	if err != nil {
-		return nil, fmt.Errorf("error while executing AGTSetDataField command: %w", err)
+		return nil, err
 	}
 
-	rawSegments, err := processRequest(r)
+	return parseField(rawSegments)
+}
+
+// RequestDataField sends AGTReqDataField command, it makes the server re-fetch the field of the current record
+// from the host and returns the field the same way as ReadField.
+func (c *Client) RequestDataField(ctx context.Context, listType ListType, fieldName string) (*Field, error) {
+	rawSegments, err := c.simpleCommand(ctx, cmdReqDataField, newArg("list_type", string([]byte{byte(listType)})), newArg("field_name", fieldName))
 	if err != nil {
 		return nil, err
 	}
 
+	return parseField(rawSegments)
+}
+
+// parseField parses the data message of the field: <FieldName>,<FieldType>,<FieldLength>,<FieldValue>
+func parseField(rawSegments []string) (*Field, error) {
 	if rawSegments == nil || len(rawSegments) != 2 || rawSegments[0] != "M00001" {
 		return nil, fmt.Errorf("invalid segment")
 	}
@@ -610,3 +1399,134 @@ func (c *Client) ReadField(ctx context.Context, listType ListType, fieldName str
 		Value:  parts[3],
 	}, nil
 }
+
+// FieldsError is returned by ReadFields and SetDataFields when some of the fields cannot be processed.
+type FieldsError struct {
+	// Errors maps the field name to its error
+	Errors map[string]error
+
+	// op is the failed operation, e.g. read
+	op string
+}
+
+func (e *FieldsError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e.Errors[name].Error())
+	}
+
+	op := e.op
+	if op == "" {
+		op = "process"
+	}
+
+	return "cannot " + op + " fields: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the field errors matches target.
+func (e *FieldsError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadFields reads the passed fields concurrently, each one with own AGTReadField command.
+// Fields are returned in the passed order; if some of them cannot be read, the rest of them
+// is returned along with *FieldsError.
+func (c *Client) ReadFields(ctx context.Context, listType ListType, fieldNames []string) ([]Field, error) {
+	var (
+		fields = make([]*Field, len(fieldNames))
+		errs   = make([]error, len(fieldNames))
+		wg     sync.WaitGroup
+	)
+
+	for i, fieldName := range fieldNames {
+		wg.Add(1)
+		go func(i int, fieldName string) {
+			defer wg.Done()
+			fields[i], errs[i] = c.ReadField(ctx, listType, fieldName)
+		}(i, fieldName)
+	}
+	wg.Wait()
+
+	result := make([]Field, 0, len(fieldNames))
+	fieldsErr := &FieldsError{Errors: make(map[string]error), op: "read"}
+	for i, fieldName := range fieldNames {
+		if errs[i] != nil {
+			fieldsErr.Errors[fieldName] = errs[i]
+			continue
+		}
+
+		result = append(result, *fields[i])
+	}
+
+	if len(fieldsErr.Errors) > 0 {
+		return result, fieldsErr
+	}
+
+	return result, nil
+}
+
+// SetDataFields registers the passed fields concurrently, each one with own AGTSetDataField command,
+// since the command accepts a single field only. If some of them cannot be registered, it returns *FieldsError.
+func (c *Client) SetDataFields(ctx context.Context, listType ListType, fieldNames ...string) error {
+	var (
+		errs = make([]error, len(fieldNames))
+		wg   sync.WaitGroup
+	)
+
+	for i, fieldName := range fieldNames {
+		wg.Add(1)
+		go func(i int, fieldName string) {
+			defer wg.Done()
+			errs[i] = c.SetDataField(ctx, listType, fieldName)
+		}(i, fieldName)
+	}
+	wg.Wait()
+
+	fieldsErr := &FieldsError{Errors: make(map[string]error), op: "set"}
+	for i, fieldName := range fieldNames {
+		if errs[i] != nil {
+			fieldsErr.Errors[fieldName] = errs[i]
+		}
+	}
+
+	if len(fieldsErr.Errors) > 0 {
+		return fieldsErr
+	}
+
+	return nil
+}
+
+// ErrValueTooLong is returned by WriteField when the value exceeds the field length.
+var ErrValueTooLong = errors.New("value is too long")
+
+// WriteField sends AGTUpdateField command, it updates the field of the current customer record.
+// If data fields of the calling list have been listed by ListDataFields, the value length is validated
+// before sending the command.
+func (c *Client) WriteField(ctx context.Context, listType ListType, fieldName, value string) error {
+	c.dataFieldsMu.RLock()
+	length, ok := c.dataFields[listType][fieldName]
+	c.dataFieldsMu.RUnlock()
+
+	if ok && utf8.RuneCountInString(value) > length {
+		return fmt.Errorf("%w: %s field is limited to %d characters", ErrValueTooLong, fieldName, length)
+	}
+
+	_, err := c.simpleCommand(ctx, cmdUpdateField,
+		newArg("list_type", string([]byte{byte(listType)})),
+		newArg("field_name", fieldName),
+		newArg("value", value),
+	)
+	return err
+}
@@ -0,0 +1,1689 @@
+package apc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/L11R/go-apc/apctest"
+	"github.com/L11R/go-apc/pool"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// newPipeClient returns the *Client connected to the one end of in-memory pipe and the other end of it.
+func newPipeClient() (*Client, net.Conn) {
+	clientConn, serverConn := net.Pipe()
+
+	c := newClient("pipe", &Options{})
+	c.setState(ConnOK)
+	c.conn = clientConn
+
+	return c, serverConn
+}
+
+// readCommand reads the single command written by the client.
+func readCommand(t *testing.T, conn net.Conn) []byte {
+	t.Helper()
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("conn.Read() error = %v", err)
+	}
+
+	return buf[:n]
+}
+
+// newRespondingClient returns started *Client connected to in-memory server that
+// answers each command with raw events returned by respond.
+func newRespondingClient(t *testing.T, respond func(command Event) []string) (*Client, func()) {
+	t.Helper()
+
+	c, server := newHandshakedClient(t, func(server net.Conn) {
+		buf := make([]byte, 4096)
+		for {
+			n, err := server.Read(buf)
+			if err != nil {
+				return
+			}
+
+			command, err := decodeEvent(string(buf[:n]))
+			if err != nil {
+				return
+			}
+
+			for _, raw := range respond(command) {
+				if _, err := server.Write([]byte(raw)); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	go func() {
+		_ = c.Start()
+	}()
+
+	return c, func() {
+		_ = c.Stop()
+		_ = server.Close()
+	}
+}
+
+// newServerClient returns started *Client connected to the fake APC server, the caller should call returned func when finished.
+func newServerClient(t *testing.T) (*Client, *apctest.Server, func()) {
+	t.Helper()
+
+	srv := apctest.NewServer()
+	c, err := NewClient(srv.Addr, WithTlsSkipVerify())
+	if err != nil {
+		srv.Close()
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	go func() {
+		_ = c.Start()
+	}()
+
+	return c, srv, func() {
+		_ = c.Stop()
+		srv.Close()
+	}
+}
+
+func TestClient_ListCompletionCodes(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{
+			rawEvent("AGTListKeys", EventTypeData, command.InvokeID, "0", "M00001", "35,Managed cancel call,cancel_call", ",*Record not yet called,pf_msg_1", "19,Recall release,call_complete"),
+			rawEvent("AGTListKeys", EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	got, err := c.ListCompletionCodes(context.Background())
+	if err != nil {
+		t.Fatalf("c.ListCompletionCodes() error = %v", err)
+	}
+
+	want := []CompletionCode{
+		{Code: 35, Description: "Managed cancel call", ScriptLabel: "cancel_call"},
+		{Code: 19, Description: "Recall release", ScriptLabel: "call_complete"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("c.ListCompletionCodes() = %#v, want %#v", got, want)
+	}
+
+	if err := c.FinishedItem(context.Background(), 22); !errors.Is(err, ErrInvalidCompletion) {
+		t.Errorf("c.FinishedItem() error = %v, want %v", err, ErrInvalidCompletion)
+	}
+}
+
+func TestClient_FinishedItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		unchecked bool
+		code      int
+		wantErr   error
+		commands  []string
+	}{
+		{
+			name:     "valid",
+			code:     35,
+			commands: []string{"AGTListKeys", "AGTFinishedItem"},
+		},
+		{
+			name:     "invalid",
+			code:     22,
+			wantErr:  ErrInvalidCompletion,
+			commands: []string{"AGTListKeys"},
+		},
+		{
+			name:      "unchecked",
+			unchecked: true,
+			code:      22,
+			commands:  []string{"AGTFinishedItem"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv, stop := newServerClient(t)
+			defer stop()
+			srv.Respond("AGTListKeys", []string{"35,Managed cancel call,cancel_call", "19,Recall release,call_complete"})
+
+			finish := c.FinishedItem
+			if tt.unchecked {
+				finish = c.FinishedItemUnchecked
+			}
+			if err := finish(context.Background(), tt.code); !errors.Is(err, tt.wantErr) {
+				t.Errorf("c.FinishedItem() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var commands []string
+			for _, command := range srv.Commands() {
+				commands = append(commands, command.Keyword)
+			}
+			if !reflect.DeepEqual(commands, tt.commands) {
+				t.Errorf("commands = %v, want %v", commands, tt.commands)
+			}
+		})
+	}
+}
+
+func TestClient_SetCompletionCode(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+	srv.Respond("AGTListKeys", []string{"35,Managed cancel call,cancel_call", "19,Recall release,call_complete"})
+
+	if err := c.SetCompletionCode(context.Background(), 22); !errors.Is(err, ErrInvalidCompletion) {
+		t.Errorf("c.SetCompletionCode() error = %v, want %v", err, ErrInvalidCompletion)
+	}
+
+	if err := c.SetCompletionCode(context.Background(), 19); err != nil {
+		t.Errorf("c.SetCompletionCode() error = %v", err)
+	}
+
+	srv.Fail("AGTSetCompCode", "E28866")
+	if err := c.SetCompletionCode(context.Background(), 19); !errors.Is(err, AvayaError{Code: "E28866"}) {
+		t.Errorf("c.SetCompletionCode() error = %v, want %v", err, AvayaError{Code: "E28866"})
+	}
+
+	// Codes are requested once, invalid code isn't sent
+	var commands []string
+	for _, command := range srv.Commands() {
+		commands = append(commands, command.Keyword+" "+strings.Join(command.Segments, ","))
+	}
+	want := []string{"AGTListKeys ", "AGTSetCompCode 19", "AGTSetCompCode 19"}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
+
+func TestClient_ListCompletionCodes_Empty(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{rawEvent("AGTListKeys", EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	got, err := c.ListCompletionCodes(context.Background())
+	if err != nil {
+		t.Fatalf("c.ListCompletionCodes() error = %v", err)
+	}
+
+	if got == nil || len(got) != 0 {
+		t.Errorf("c.ListCompletionCodes() = %#v, want empty slice", got)
+	}
+}
+
+func TestClient_ManualCall(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.ManualCall(ctx, "89001234567")
+	}()
+
+	raw := readCommand(t, server)
+	cancel()
+	<-done
+
+	event, err := decodeEvent(string(raw))
+	if err != nil {
+		t.Fatalf("decodeEvent() error = %v", err)
+	}
+
+	if event.Keyword != "AGTManualCall" {
+		t.Errorf("event.Keyword = %v, want AGTManualCall", event.Keyword)
+	}
+
+	if len(event.Segments) != 1 || event.Segments[0] != "89001234567" {
+		t.Errorf("event.Segments = %#v, want [89001234567]", event.Segments)
+	}
+
+	if !bytes.HasSuffix(raw, []byte(string(RS)+"89001234567"+string(ETX))) {
+		t.Errorf("raw = %q, want phone number segment at the end", raw)
+	}
+}
+
+func TestClient_SendMessage(t *testing.T) {
+	commands := make(chan Event, 1)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		commands <- command
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	if err := c.SendMessage(context.Background(), "Need help with the customer"); err != nil {
+		t.Fatalf("c.SendMessage() error = %v", err)
+	}
+
+	// The message is the only segment, the recipient cannot be passed
+	command := <-commands
+	if command.Keyword != "AGTSendMessage" {
+		t.Errorf("command.Keyword = %v, want AGTSendMessage", command.Keyword)
+	}
+	if want := []string{"Need help with the customer"}; !reflect.DeepEqual(command.Segments, want) {
+		t.Errorf("command.Segments = %#v, want %#v", command.Segments, want)
+	}
+}
+
+func TestClient_SendMessage_TooLong(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	if err := c.SendMessage(context.Background(), strings.Repeat("я", MaxMessageLength+1)); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("c.SendMessage() error = %v, want %v", err, ErrMessageTooLong)
+	}
+}
+
+func TestClient_invokeCommand_Encoder(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+	c.opts.Encoder = charmap.Windows1251.NewEncoder()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.AttachJob(ctx, "Входящие")
+	}()
+
+	raw := readCommand(t, server)
+	cancel()
+	<-done
+
+	want := []byte{RS, 0xC2, 0xF5, 0xEE, 0xE4, 0xFF, 0xF9, 0xE8, 0xE5, ETX}
+	if !bytes.HasSuffix(raw, want) {
+		t.Errorf("raw = %q, want Windows-1251 encoded job name at the end", raw)
+	}
+}
+
+func TestClient_invokeCommand_ClientHeader(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+	WithClientName("Desktop-01")(c.opts)
+	WithProcessID(123456)(c.opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.EchoOn(ctx)
+	}()
+
+	raw := readCommand(t, server)
+	cancel()
+	<-done
+
+	if client := string(raw[21:41]); client != fmt.Sprintf("%-20s", "Desktop-01") {
+		t.Errorf("client = %q, want %q", client, "Desktop-01")
+	}
+
+	if pid := string(raw[41:47]); pid != "123456" {
+		t.Errorf("pid = %q, want %q", pid, "123456")
+	}
+
+	c.opts.ClientName = strings.Repeat("a", 21)
+	if err := c.EchoOn(context.Background()); err == nil {
+		t.Errorf("c.EchoOn() with too long client name error = nil, want error")
+	}
+
+	c.opts.ClientName = ""
+	c.opts.ProcessID = 1000000
+	if err := c.EchoOn(context.Background()); err == nil {
+		t.Errorf("c.EchoOn() with too long process id error = nil, want error")
+	}
+}
+
+func TestClient_invokeCommand_CommandTimeout(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+	c.opts.CommandTimeout = 50 * time.Millisecond
+
+	// Server reads the command, but never responds
+	go func() {
+		_, _ = server.Read(make([]byte, 4096))
+	}()
+
+	if err := c.EchoOn(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("c.EchoOn() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_invokeCommand_TraceHook(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	var (
+		gotKeyword  string
+		gotInvokeID uint32
+	)
+	c.opts.TraceHook = func(keyword string, invokeID uint32) {
+		gotKeyword, gotInvokeID = keyword, invokeID
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.EchoOn(ctx)
+	}()
+
+	raw := readCommand(t, server)
+	cancel()
+	<-done
+
+	event, err := decodeEvent(string(raw))
+	if err != nil {
+		t.Fatalf("decodeEvent() error = %v", err)
+	}
+
+	if gotKeyword != "AGTEchoOn" || gotInvokeID != event.InvokeID {
+		t.Errorf("hook got (%v, %v), want (AGTEchoOn, %v)", gotKeyword, gotInvokeID, event.InvokeID)
+	}
+}
+
+func TestClient_invokeCommand_TooManyInFlight(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	for i := 0; i < pool.MaxInvokeID; i++ {
+		c.invokeIDPool.Get()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := c.EchoOn(ctx)
+	if !errors.Is(err, ErrTooManyInFlight) {
+		t.Errorf("c.EchoOn() error = %v, want %v", err, ErrTooManyInFlight)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("c.EchoOn() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_ListJobs_ManyDataMessages(t *testing.T) {
+	const n = 1000
+
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		events := make([]string, 0, n+1)
+		for i := 0; i < n; i++ {
+			events = append(events, rawEvent("AGTListJobs", EventTypeData, command.InvokeID, "0", "M00001", "O,job"+strconv.Itoa(i)+",A"))
+		}
+		return append(events, rawEvent("AGTListJobs", EventTypeResponse, command.InvokeID, "0", "M00000"))
+	})
+	defer stop()
+
+	jobs, err := c.ListJobs(context.Background(), JobTypeAll)
+	if err != nil {
+		t.Fatalf("c.ListJobs() error = %v", err)
+	}
+
+	if len(jobs) != n {
+		t.Fatalf("len(c.ListJobs()) = %v, want %v", len(jobs), n)
+	}
+
+	if jobs[n-1].Name != "job999" {
+		t.Errorf("jobs[n-1].Name = %v, want job999", jobs[n-1].Name)
+	}
+}
+
+func TestClient_ListJobs_Malformed(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{
+			rawEvent("AGTListJobs", EventTypeData, command.InvokeID, "0", "M00001", ",job1,A", "O,,A", "X,job2,", "", "O,job3,A"),
+			rawEvent("AGTListJobs", EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	jobs, err := c.ListJobs(context.Background(), JobTypeAll)
+	if err != nil {
+		t.Fatalf("c.ListJobs() error = %v", err)
+	}
+
+	want := []Job{
+		{Type: JobTypeUnknown, Name: "job1", Status: StatusTypeActive},
+		{Type: JobTypeUnknown, Name: "job2", Status: StatusTypeUnknown},
+		{Type: JobTypeOutbound, Name: "job3", Status: StatusTypeActive},
+	}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Errorf("c.ListJobs() = %#v, want %#v", jobs, want)
+	}
+}
+
+func TestClient_Start_AbandonedRequest(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword == "AGTEchoOn" {
+			return []string{rawEvent("AGTEchoOn", EventTypeResponse, command.InvokeID, "0", "M00000")}
+		}
+
+		// Flood the request that nobody reads
+		events := make([]string, 0, 100)
+		for i := 0; i < 100; i++ {
+			events = append(events, rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "O,job,A"))
+		}
+		return events
+	})
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, invokeID, err := c.invokeCommand(ctx, "AGTListJobs", newArg("job_type", "A"))
+	defer c.destroyCommand(invokeID)
+	if err != nil {
+		t.Fatalf("c.invokeCommand() error = %v", err)
+	}
+	cancel()
+
+	echoCtx, echoCancel := context.WithTimeout(context.Background(), time.Second)
+	defer echoCancel()
+
+	if err := c.EchoOn(echoCtx); err != nil {
+		t.Errorf("c.EchoOn() error = %v", err)
+	}
+}
+
+func TestClient_destroyCommand(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+	c.opts.CommandTimeout = time.Hour
+
+	r, invokeID, err := c.invokeCommand(context.Background(), "AGTEchoOn")
+	if err != nil {
+		c.destroyCommand(invokeID)
+		t.Fatalf("c.invokeCommand() error = %v", err)
+	}
+	if _, err := processRequest(r); err != nil {
+		t.Errorf("processRequest() error = %v", err)
+	}
+	c.destroyCommand(invokeID)
+
+	// Context of the processed request must not wait for the command timeout
+	if err := r.context.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("r.context.Err() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestClient_ReadFields(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		switch command.Segments[1] {
+		case "NAME":
+			return []string{
+				rawEvent("AGTReadField", EventTypeData, command.InvokeID, "0", "M00001", "NAME,A,20,JOHN DOE"),
+				rawEvent("AGTReadField", EventTypeResponse, command.InvokeID, "0", "M00000"),
+			}
+		case "BALANCE":
+			return []string{
+				rawEvent("AGTReadField", EventTypeData, command.InvokeID, "0", "M00001", "BALANCE,$,10,100.00"),
+				rawEvent("AGTReadField", EventTypeResponse, command.InvokeID, "0", "M00000"),
+			}
+		default:
+			return []string{rawEvent("AGTReadField", EventTypeResponse, command.InvokeID, "1", "E28894")}
+		}
+	})
+	defer stop()
+
+	fields, err := c.ReadFields(context.Background(), ListTypeOutbound, []string{"NAME", "UNKNOWN", "BALANCE"})
+	if !errors.Is(err, ErrFieldNotFound) {
+		t.Errorf("c.ReadFields() error = %v, want %v", err, ErrFieldNotFound)
+	}
+
+	want := []Field{
+		{Name: "NAME", Type: FieldTypeAlphanumeric, Length: 20, Value: "JOHN DOE"},
+		{Name: "BALANCE", Type: FieldTypeCurrency, Length: 10, Value: "100.00"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("c.ReadFields() = %#v, want %#v", fields, want)
+	}
+}
+
+func TestClient_SetDataFields(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Segments[1] == "UNKNOWN" {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28894")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	if err := c.SetDataFields(context.Background(), ListTypeOutbound, "NAME", "BALANCE"); err != nil {
+		t.Errorf("c.SetDataFields() error = %v", err)
+	}
+
+	err := c.SetDataFields(context.Background(), ListTypeOutbound, "NAME", "UNKNOWN")
+	if !errors.Is(err, ErrFieldNotFound) {
+		t.Errorf("c.SetDataFields() error = %v, want %v", err, ErrFieldNotFound)
+	}
+
+	var fieldsErr *FieldsError
+	if !errors.As(err, &fieldsErr) || len(fieldsErr.Errors) != 1 || fieldsErr.Errors["UNKNOWN"] == nil {
+		t.Errorf("c.SetDataFields() error = %#v, want *FieldsError with UNKNOWN field", err)
+	}
+}
+
+func TestClient_WriteField(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword == "AGTListDataFields" {
+			return []string{
+				rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "ACCTNUM,16,N,F", "NAME,26,C,F"),
+				rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+			}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	fields, err := c.ListDataFields(context.Background(), ListTypeOutbound)
+	if err != nil {
+		t.Fatalf("c.ListDataFields() error = %v", err)
+	}
+
+	want := []DataField{
+		{Name: "ACCTNUM", Length: 16, Type: FieldTypeNumeric},
+		{Name: "NAME", Length: 26, Type: FieldTypeCharacter},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("c.ListDataFields() = %#v, want %#v", fields, want)
+	}
+
+	if err := c.WriteField(context.Background(), ListTypeOutbound, "NAME", "JOHN DOE"); err != nil {
+		t.Errorf("c.WriteField() error = %v", err)
+	}
+
+	if err := c.WriteField(context.Background(), ListTypeOutbound, "NAME", strings.Repeat("A", 27)); !errors.Is(err, ErrValueTooLong) {
+		t.Errorf("c.WriteField() error = %v, want %v", err, ErrValueTooLong)
+	}
+}
+
+func TestClient_ListStates(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{
+			rawEvent("AGTListState", EventTypeData, command.InvokeID, "0", "S70002,outbound1", "S70001,outbound1"),
+			rawEvent("AGTListState", EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	got, err := c.ListStates(context.Background())
+	if err != nil {
+		t.Fatalf("c.ListStates() error = %v", err)
+	}
+
+	want := []State{
+		{Type: StateTypeHasJoinedJob, JobName: "outbound1"},
+		{Type: StateTypeReadyForCall, JobName: "outbound1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("c.ListStates() = %#v, want %#v", got, want)
+	}
+}
+
+func TestClient_ListState_NoJobName(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{
+			rawEvent("AGTListState", EventTypeData, command.InvokeID, "0", "S70004"),
+			rawEvent("AGTListState", EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	got, err := c.ListState(context.Background())
+	if err != nil {
+		t.Fatalf("c.ListState() error = %v", err)
+	}
+
+	if want := (&State{Type: StateTypeLoggedOn}); !reflect.DeepEqual(got, want) {
+		t.Errorf("c.ListState() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    State
+		wantErr bool
+	}{
+		{segment: "S70004", want: State{Type: StateTypeLoggedOn}},
+		{segment: "S70003,outbound1", want: State{Type: StateTypeHasSelectedJob, JobName: "outbound1"}},
+		{segment: "", wantErr: true},
+		{segment: "S70003,outbound1,extra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseState(tt.segment)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseState(%q) error = %v, wantErr %v", tt.segment, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseState(%q) = %#v, want %#v", tt.segment, got, tt.want)
+		}
+	}
+}
+
+func TestCallbackFormat_Layout(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "YYYY/MM/DD", want: "2006/01/02"},
+		{format: "MM/DD/YY", want: "01/02/06"},
+		{format: "DD/MM/YY", want: "02/01/06"},
+		{format: "1999/03/06", want: "2006/01/02"},
+		{format: "03/06/99", wantErr: true},
+		{format: "YYY/MM/DD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := (&CallbackFormat{DateFormat: tt.format}).Layout()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Layout(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Layout(%q) = %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestClient_SetCallback(t *testing.T) {
+	commands := make(chan Event, 2)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		commands <- command
+		if command.Keyword == "AGTListCallbackFmt" {
+			return []string{
+				rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "YYYY/MM/DD", "2"),
+				rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+			}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	err := c.SetCallback(context.Background(), Callback{
+		Time:       time.Date(2020, 5, 14, 16, 30, 0, 0, time.UTC),
+		PhoneIndex: 2,
+	})
+	if err != nil {
+		t.Fatalf("c.SetCallback() error = %v", err)
+	}
+
+	<-commands
+	command := <-commands
+
+	if want := []string{"2020/05/14", "1630", "2"}; !reflect.DeepEqual(command.Segments, want) {
+		t.Errorf("command.Segments = %#v, want %#v", command.Segments, want)
+	}
+}
+
+func TestClient_SendDTMF(t *testing.T) {
+	digits := make(chan string, 8)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		digits <- strings.Join(command.Segments, "")
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	if err := c.SendDTMF(context.Background(), "12a#"); !errors.Is(err, ErrInvalidDigit) {
+		t.Errorf("c.SendDTMF() error = %v, want %v", err, ErrInvalidDigit)
+	}
+
+	if err := c.SendDTMF(context.Background(), "0*9#"); err != nil {
+		t.Fatalf("c.SendDTMF() error = %v", err)
+	}
+
+	close(digits)
+	var got string
+	for d := range digits {
+		got += d
+	}
+
+	if got != "0*9#" {
+		t.Errorf("sent digits = %q, want %q", got, "0*9#")
+	}
+}
+
+func TestParseStatusType(t *testing.T) {
+	tests := []struct {
+		status string
+		want   StatusType
+	}{
+		{status: "I", want: StatusTypeInactive},
+		{status: "A", want: StatusTypeActive},
+		{status: "P", want: StatusTypeUnknown},
+		{status: "", want: StatusTypeUnknown},
+		{status: "AI", want: StatusTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := parseStatusType(tt.status); got != tt.want {
+			t.Errorf("parseStatusType(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestClient_LogonWithResult(t *testing.T) {
+	commands := make(chan Event, 1)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		commands <- command
+		return []string{
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "AGENT_ID,1001"),
+			rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000", "SESSION,42"),
+		}
+	})
+	defer stop()
+	WithProtocolVersion("PCAPI_5.1.0.0.4")(c.opts)
+
+	session, err := c.LogonWithResult(context.Background(), "agent1", "secret")
+	if err != nil {
+		t.Fatalf("c.LogonWithResult() error = %v", err)
+	}
+
+	want := &Session{
+		AgentName: "agent1",
+		Version:   "PCAPI_5.1.0.0.4",
+		Data:      []string{"AGENT_ID,1001"},
+		Response:  []string{"SESSION,42"},
+	}
+	if !reflect.DeepEqual(session, want) {
+		t.Errorf("c.LogonWithResult() = %#v, want %#v", session, want)
+	}
+
+	if command := <-commands; !reflect.DeepEqual(command.Segments, []string{"agent1", "secret", "PCAPI_5.1.0.0.4"}) {
+		t.Errorf("command.Segments = %#v, want agent name, password and version", command.Segments)
+	}
+}
+
+func TestClient_ChangePassword(t *testing.T) {
+	commands := make(chan Event, 2)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		commands <- command
+		if command.Keyword == "AGTSetPassword" && command.Segments[2] == "short" {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E70017")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	if err := c.ChangePassword(context.Background(), "secret1", "secret2"); !errors.Is(err, ErrNotLoggedOn) {
+		t.Errorf("c.ChangePassword() error = %v, want %v", err, ErrNotLoggedOn)
+	}
+
+	if err := c.Logon(context.Background(), "agent1", "secret1"); err != nil {
+		t.Fatalf("c.Logon() error = %v", err)
+	}
+	<-commands
+
+	if err := c.ChangePassword(context.Background(), "secret1", "short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("c.ChangePassword() error = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	if command := <-commands; !reflect.DeepEqual(command.Segments, []string{"agent1", "secret1", "short"}) {
+		t.Errorf("command.Segments = %#v, want agent name, old and new passwords", command.Segments)
+	}
+}
+
+func TestClient_invokeCommand_Busy(t *testing.T) {
+	var busy bool
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if busy = !busy; busy {
+			return []string{rawEvent(command.Keyword, EventTypeBusy, command.InvokeID, "0")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	if err := c.EchoOn(context.Background()); !errors.Is(err, ErrServerBusy) {
+		t.Errorf("c.EchoOn() error = %v, want %v", err, ErrServerBusy)
+	}
+
+	if err := c.EchoOn(context.Background()); err != nil {
+		t.Errorf("c.EchoOn() after busy error = %v", err)
+	}
+}
+
+func TestClient_invokeCommand_MaxPending(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{rawEvent(command.Keyword, EventTypePending, command.InvokeID, "0", "S28833")}
+	})
+	defer stop()
+	c.opts.MaxPending = 50 * time.Millisecond
+
+	if err := c.EchoOn(context.Background()); !errors.Is(err, ErrPendingTimeout) {
+		t.Errorf("c.EchoOn() error = %v, want %v", err, ErrPendingTimeout)
+	}
+}
+
+func TestClient_invokeCommand_Serialized(t *testing.T) {
+	commands := make(chan Event)
+	c, server := newHandshakedClient(t, func(server net.Conn) {
+		buf := make([]byte, 4096)
+		for {
+			n, err := server.Read(buf)
+			if err != nil {
+				close(commands)
+				return
+			}
+
+			command, err := decodeEvent(string(buf[:n]))
+			if err != nil {
+				close(commands)
+				return
+			}
+			commands <- command
+		}
+	})
+	defer server.Close()
+	c.serial = make(chan struct{}, 1)
+
+	go func() {
+		_ = c.Start()
+	}()
+	defer c.Stop()
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- c.EchoOn(context.Background())
+		}()
+	}
+
+	first := <-commands
+	select {
+	case <-commands:
+		t.Fatalf("the second command is sent before the first one is processed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := server.Write([]byte(rawEvent(first.Keyword, EventTypeResponse, first.InvokeID, "0", "M00000"))); err != nil {
+		t.Fatalf("server.Write() error = %v", err)
+	}
+
+	second := <-commands
+	if _, err := server.Write([]byte(rawEvent(second.Keyword, EventTypeResponse, second.InvokeID, "0", "M00000"))); err != nil {
+		t.Fatalf("server.Write() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("c.EchoOn() error = %v", err)
+		}
+	}
+}
+
+func TestClient_invokeCommand_SerializedCancelled(t *testing.T) {
+	c, server := newPipeClient()
+	defer server.Close()
+
+	// The slot is taken by another command
+	c.serial = make(chan struct{}, 1)
+	c.serial <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := c.EchoOn(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("c.EchoOn() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if errors.Is(err, ErrTooManyInFlight) {
+		t.Errorf("c.EchoOn() error = %v, want not %v", err, ErrTooManyInFlight)
+	}
+}
+
+func TestClient_CallLists(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name string
+		data [][]string
+		want []CallList
+	}{
+		{
+			name: "names",
+			data: [][]string{{"inbnd1", "inbrpt"}},
+			want: []CallList{{Name: "inbnd1"}, {Name: "inbrpt"}},
+		},
+		{
+			name: "flags",
+			data: [][]string{{"late1,A", "list1,A,L"}},
+			want: []CallList{{Name: "late1", Flags: []string{"A"}}, {Name: "list1", Flags: []string{"A", "L"}}},
+		},
+		{
+			name: "empty",
+			want: []CallList{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond("AGTListCallLists", tt.data...)
+
+			got, err := c.CallLists(context.Background())
+			if err != nil {
+				t.Fatalf("c.CallLists() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("c.CallLists() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_CallFields(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name    string
+		setup   func()
+		want    []CallField
+		wantErr error
+	}{
+		{
+			name: "fields",
+			setup: func() {
+				srv.Respond("AGTListCallFields", []string{"SYSNUM,4,N,F", "NAME,26,C,F", "BAL,10,$,F"})
+			},
+			want: []CallField{
+				{Name: "SYSNUM", Length: 4, Type: FieldTypeNumeric},
+				{Name: "NAME", Length: 26, Type: FieldTypeCharacter},
+				{Name: "BAL", Length: 10, Type: FieldTypeCurrency},
+			},
+		},
+		{
+			name: "invalid length",
+			setup: func() {
+				srv.Respond("AGTListCallFields", []string{"NAME,X,C,F"})
+			},
+			wantErr: strconv.ErrSyntax,
+		},
+		{
+			name: "unknown list",
+			setup: func() {
+				srv.Fail("AGTListCallFields", "E00518")
+			},
+			wantErr: AvayaError{Code: "E00518"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			got, err := c.CallFields(context.Background(), "list1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("c.CallFields() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("c.CallFields() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	commands := srv.Commands()
+	if last := commands[len(commands)-1]; !reflect.DeepEqual(last.Segments, []string{"list1"}) {
+		t.Errorf("command.Segments = %v, want [list1]", last.Segments)
+	}
+}
+
+func TestClient_AttachJobWithOptions(t *testing.T) {
+	commands := make(chan Event, 3)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		commands <- command
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	err := c.AttachJobWithOptions(context.Background(), "uwl1", JobAttachOptions{
+		WorkClass: WorkClassOutbound,
+		Units:     []string{"U1", "U2"},
+	})
+	if err != nil {
+		t.Fatalf("c.AttachJobWithOptions() error = %v", err)
+	}
+
+	want := []Event{
+		{Keyword: "AGTSetWorkClass", Segments: []string{"O"}},
+		{Keyword: "AGTAttachJob", Segments: []string{"uwl1"}},
+		{Keyword: "AGTSetUnit", Segments: []string{"U1|U2"}},
+	}
+	for _, w := range want {
+		got := <-commands
+		if got.Keyword != w.Keyword || !reflect.DeepEqual(got.Segments, w.Segments) {
+			t.Errorf("command = %s %#v, want %s %#v", got.Keyword, got.Segments, w.Keyword, w.Segments)
+		}
+	}
+}
+
+func TestClient_HeadsetStatus(t *testing.T) {
+	states := []string{"C,1001", "R", "X"}
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		state := states[0]
+		states = states[1:]
+
+		return []string{
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", state),
+			rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	for _, want := range []HeadsetState{HeadsetStateConnected, HeadsetStateReserved, HeadsetStateUnknown} {
+		got, err := c.HeadsetStatus(context.Background())
+		if err != nil {
+			t.Fatalf("c.HeadsetStatus() error = %v", err)
+		}
+
+		if got != want {
+			t.Errorf("c.HeadsetStatus() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestClient_EnsureHeadsetReserved(t *testing.T) {
+	tests := []struct {
+		status       []string
+		wantErr      error
+		wantHeadset  int64
+		wantCommands []string
+	}{
+		{
+			status:       []string{"0", "M00001", "R"},
+			wantHeadset:  1001,
+			wantCommands: []string{"AGTReqHeadset"},
+		},
+		{
+			status:       []string{"0", "M00001", "C,1001"},
+			wantHeadset:  1001,
+			wantCommands: []string{"AGTReqHeadset"},
+		},
+		{
+			status:       []string{"0", "M00001", "R,1002"},
+			wantErr:      ErrHeadsetMismatch,
+			wantCommands: []string{"AGTReqHeadset"},
+		},
+		{
+			status:       []string{"0", "M00001", "F"},
+			wantHeadset:  1001,
+			wantCommands: []string{"AGTReqHeadset", "AGTReserveHeadset"},
+		},
+		{
+			status:       []string{"1", "E70001"},
+			wantHeadset:  1001,
+			wantCommands: []string{"AGTReqHeadset", "AGTReserveHeadset"},
+		},
+	}
+
+	for _, tt := range tests {
+		var keywords []string
+		c, stop := newRespondingClient(t, func(command Event) []string {
+			keywords = append(keywords, command.Keyword)
+			if command.Keyword != "AGTReqHeadset" {
+				return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+			}
+
+			if tt.status[0] == "1" {
+				return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, tt.status...)}
+			}
+
+			return []string{
+				rawEvent(command.Keyword, EventTypeData, command.InvokeID, tt.status...),
+				rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+			}
+		})
+
+		if err := c.EnsureHeadsetReserved(context.Background(), 1001); !errors.Is(err, tt.wantErr) {
+			t.Errorf("c.EnsureHeadsetReserved() error = %v, want %v", err, tt.wantErr)
+		}
+		stop()
+
+		if !reflect.DeepEqual(keywords, tt.wantCommands) {
+			t.Errorf("commands = %v, want %v", keywords, tt.wantCommands)
+		}
+
+		if got := c.reservedHeadset.Load(); got != tt.wantHeadset {
+			t.Errorf("c.reservedHeadset = %v, want %v", got, tt.wantHeadset)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    Key
+		wantErr bool
+	}{
+		{
+			segment: "35,Managed cancel call,cancel_call",
+			want:    Key{Position: 1, Code: 35, Description: "Managed cancel call", ScriptLabel: "cancel_call", Enabled: true},
+		},
+		{
+			segment: "20,Sale, paid by card,sale",
+			want:    Key{Position: 1, Code: 20, Description: "Sale", ScriptLabel: " paid by card,sale", Enabled: true},
+		},
+		{
+			segment: ",,",
+			want:    Key{Position: 1},
+		},
+		{segment: "35,Managed cancel call", wantErr: true},
+		{segment: "XX,Unknown,unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseKey(1, tt.segment)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseKey(%q) error = %v, wantErr %v", tt.segment, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseKey(%q) = %#v, want %#v", tt.segment, got, tt.want)
+		}
+	}
+}
+
+func TestClient_ListKeyBindings(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "35,Managed cancel call,cancel_call", ",,"),
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "89,Managed non-connect,nonconnect"),
+			rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	keys, err := c.ListKeyBindings(context.Background())
+	if err != nil {
+		t.Fatalf("c.ListKeyBindings() error = %v", err)
+	}
+
+	want := []Key{
+		{Position: 1, Code: 35, Description: "Managed cancel call", ScriptLabel: "cancel_call", Enabled: true},
+		{Position: 2},
+		{Position: 3, Code: 89, Description: "Managed non-connect", ScriptLabel: "nonconnect", Enabled: true},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("c.ListKeyBindings() = %#v, want %#v", keys, want)
+	}
+}
+
+func TestClient_ManagedCall(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword != "AGTManagedCall" {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28800")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28907")}
+	})
+	defer stop()
+
+	if err := c.ManagedCall(context.Background()); !errors.Is(err, ErrNotManagedJob) {
+		t.Errorf("c.ManagedCall() error = %v, want %v", err, ErrNotManagedJob)
+	}
+}
+
+func TestClient_PreviewRecord(t *testing.T) {
+	keywords := make(chan string, 1)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords <- command.Keyword
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	tests := []struct {
+		name    string
+		do      func(ctx context.Context) error
+		keyword string
+	}{
+		{name: "PreviewRecord", do: c.PreviewRecord, keyword: "AGTPreviewRecord"},
+		{name: "DialPreview", do: c.DialPreview, keyword: "AGTManagedCall"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.do(context.Background()); err != nil {
+				t.Errorf("c.%s() error = %v", tt.name, err)
+			}
+
+			if keyword := <-keywords; keyword != tt.keyword {
+				t.Errorf("keyword = %v, want %v", keyword, tt.keyword)
+			}
+		})
+	}
+}
+
+func TestClient_RequestDataField(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword != "AGTReqDataField" || !reflect.DeepEqual(command.Segments, []string{"O", "NAME"}) {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28800")}
+		}
+
+		return []string{
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "NAME,A,20,JANE DOE"),
+			rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	field, err := c.RequestDataField(context.Background(), ListTypeOutbound, "NAME")
+	if err != nil {
+		t.Fatalf("c.RequestDataField() error = %v", err)
+	}
+
+	want := &Field{Name: "NAME", Type: FieldTypeAlphanumeric, Length: 20, Value: "JANE DOE"}
+	if !reflect.DeepEqual(field, want) {
+		t.Errorf("c.RequestDataField() = %#v, want %#v", field, want)
+	}
+}
+
+func TestClient_simpleCommand(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword == "AGTGetAppData" {
+			return []string{
+				rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "value"),
+				rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+			}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28924")}
+	})
+	defer stop()
+
+	segments, err := c.simpleCommand(context.Background(), "AGTGetAppData", newArg("key", "key"))
+	if err != nil {
+		t.Fatalf("c.simpleCommand() error = %v", err)
+	}
+
+	if want := []string{"M00001", "value"}; !reflect.DeepEqual(segments, want) {
+		t.Errorf("c.simpleCommand() = %v, want %v", segments, want)
+	}
+
+	if _, err := c.simpleCommand(context.Background(), "AGTAvailWork"); !errors.Is(err, ErrNotLoggedOn) {
+		t.Errorf("c.simpleCommand() error = %v, want %v", err, ErrNotLoggedOn)
+	}
+}
+
+func TestClient_simpleCommand_NotConnected(t *testing.T) {
+	c := newClient("pipe", &Options{})
+	ctx := context.Background()
+
+	tests := []struct {
+		keyword string
+		call    func() error
+	}{
+		{keyword: "AGTLogon", call: func() error { return c.Logon(ctx, "agent1", "secret") }},
+		{keyword: "AGTReadField", call: func() error { _, err := c.ReadField(ctx, ListTypeOutbound, "NAME"); return err }},
+		{keyword: "AGTSetDataField", call: func() error { return c.SetDataField(ctx, ListTypeOutbound, "NAME") }},
+		{keyword: "AGTUpdateField", call: func() error { return c.WriteField(ctx, ListTypeOutbound, "NAME", "JOHN") }},
+		{keyword: "AGTReqHeadset", call: func() error { _, err := c.HeadsetStatus(ctx); return err }},
+		{keyword: "AGTDisconnHeadset", call: func() error { return c.DisconnectHeadset(ctx) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, ErrConnectionClosed) {
+				t.Fatalf("error = %v, want %v", err, ErrConnectionClosed)
+			}
+
+			if want := "error while executing " + tt.keyword + " command"; !strings.Contains(err.Error(), want) {
+				t.Errorf("error = %v, want to contain %q", err, want)
+			}
+		})
+	}
+}
+
+func TestClient_AnswerCall(t *testing.T) {
+	var alerting bool
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword != "AGTAnswerCall" || !alerting {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28866")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	if err := c.AnswerCall(context.Background()); !errors.Is(err, ErrNoActiveCall) {
+		t.Errorf("c.AnswerCall() error = %v, want %v", err, ErrNoActiveCall)
+	}
+
+	alerting = true
+	if err := c.AnswerCall(context.Background()); err != nil {
+		t.Errorf("c.AnswerCall() error = %v", err)
+	}
+}
+
+func TestClient_ConferenceCall(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	srv.Respond("AGTTransferCall")
+
+	if err := c.TransferCall(context.Background(), "5001"); err != nil {
+		t.Fatalf("c.TransferCall() error = %v", err)
+	}
+	if err := c.ConferenceCall(context.Background()); err != nil {
+		t.Errorf("c.ConferenceCall() error = %v", err)
+	}
+
+	var commands []apctest.Command
+	for _, cmd := range srv.Commands() {
+		if cmd.Keyword == "AGTTransferCall" {
+			commands = append(commands, cmd)
+		}
+	}
+	if len(commands) != 2 {
+		t.Fatalf("AGTTransferCall commands = %d, want 2", len(commands))
+	}
+	if want := []string{"5001"}; !reflect.DeepEqual(commands[0].Segments, want) {
+		t.Errorf("TransferCall segments = %v, want %v", commands[0].Segments, want)
+	}
+	if len(commands[1].Segments) != 0 {
+		t.Errorf("ConferenceCall segments = %v, want none", commands[1].Segments)
+	}
+}
+
+func TestClient_Execute(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		if command.Keyword != "AGTSetWorkClass" || !reflect.DeepEqual(command.Segments, []string{"B"}) {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28800")}
+		}
+
+		return []string{
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "data"),
+			rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	segments, err := c.Execute(context.Background(), "AGTSetWorkClass", "B")
+	if err != nil {
+		t.Fatalf("c.Execute() error = %v", err)
+	}
+
+	if want := []string{"M00001", "data"}; !reflect.DeepEqual(segments, want) {
+		t.Errorf("c.Execute() = %v, want %v", segments, want)
+	}
+
+	if _, err := c.Execute(context.Background(), "AGTVeryLongKeywordName"); !errors.Is(err, ErrInvalidKeyword) {
+		t.Errorf("c.Execute() error = %v, want %v", err, ErrInvalidKeyword)
+	}
+}
+
+func TestClient_HoldCall(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name    string
+		call    func(ctx context.Context) error
+		keyword string
+	}{
+		{name: "HoldCall", call: c.HoldCall, keyword: "AGTHoldCall"},
+		{name: "ReconnectCall", call: c.ReconnectCall, keyword: "AGTUnholdCall"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond(tt.keyword)
+			if err := tt.call(context.Background()); err != nil {
+				t.Errorf("c.%s() error = %v", tt.name, err)
+			}
+
+			// The call was already released
+			srv.Fail(tt.keyword, "E28866")
+			err := tt.call(context.Background())
+			if want := (AvayaError{Code: "E28866"}); !errors.Is(err, want) {
+				t.Errorf("c.%s() error = %v, want %v", tt.name, err, want)
+			}
+
+			commands := srv.Commands()
+			if keyword := commands[len(commands)-1].Keyword; keyword != tt.keyword {
+				t.Errorf("keyword = %v, want %v", keyword, tt.keyword)
+			}
+		})
+	}
+}
+
+func TestClient_TransferCall(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name      string
+		extension string
+		code      string
+		wantErr   error
+	}{
+		{name: "transferred", extension: "5001"},
+		{name: "invalid extension", extension: "99999", code: "E28628", wantErr: AvayaError{Code: "E28628"}},
+		{name: "no call", extension: "5001", code: "E28866", wantErr: ErrNoActiveCall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond("AGTTransferCall")
+			if tt.code != "" {
+				srv.Fail("AGTTransferCall", tt.code)
+			}
+
+			if err := c.TransferCall(context.Background(), tt.extension); !errors.Is(err, tt.wantErr) {
+				t.Errorf("c.TransferCall() error = %v, want %v", err, tt.wantErr)
+			}
+
+			commands := srv.Commands()
+			if segments := commands[len(commands)-1].Segments; !reflect.DeepEqual(segments, []string{tt.extension}) {
+				t.Errorf("command.Segments = %v, want [%v]", segments, tt.extension)
+			}
+		})
+	}
+}
+
+func TestClient_TransferCall_Cancelled(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	// Server never responds, so only the context ends the command
+	srv.Handle("AGTTransferCall", func(cmd apctest.Command) []apctest.Event {
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := c.TransferCall(ctx, "5001"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("c.TransferCall() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_ClearCallback(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "cleared"},
+		{name: "no record", code: "E28866", wantErr: ErrNoActiveCall},
+		{name: "unsupported", code: "E70000", wantErr: AvayaError{Code: "E70000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond("AGTClearCallback")
+			if tt.code != "" {
+				srv.Fail("AGTClearCallback", tt.code)
+			}
+
+			if err := c.ClearCallback(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("c.ClearCallback() error = %v, want %v", err, tt.wantErr)
+			}
+
+			commands := srv.Commands()
+			if command := commands[len(commands)-1]; command.Keyword != "AGTClearCallback" || len(command.Segments) != 0 {
+				t.Errorf("command = %v %v, want AGTClearCallback without segments", command.Keyword, command.Segments)
+			}
+		})
+	}
+}
+
+func TestClient_PlayMessage(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name      string
+		messageID string
+		code      string
+		wantErr   error
+	}{
+		{name: "played", messageID: "disclosure1"},
+		{name: "no call", messageID: "disclosure1", code: "E28866", wantErr: ErrNoActiveCall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond("AGTPlayMessage")
+			if tt.code != "" {
+				srv.Fail("AGTPlayMessage", tt.code)
+			}
+
+			if err := c.PlayMessage(context.Background(), tt.messageID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("c.PlayMessage() error = %v, want %v", err, tt.wantErr)
+			}
+
+			commands := srv.Commands()
+			if segments := commands[len(commands)-1].Segments; !reflect.DeepEqual(segments, []string{tt.messageID}) {
+				t.Errorf("command.Segments = %v, want [%v]", segments, tt.messageID)
+			}
+		})
+	}
+}
+
+func TestClient_ListActiveJobs(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name string
+		jobs []string
+		want []Job
+	}{
+		{
+			name: "mixed",
+			jobs: []string{"O,outbound1,A", "O,outbound2,I", "O,outbound3,A"},
+			want: []Job{
+				{Type: JobTypeOutbound, Name: "outbound1", Status: StatusTypeActive},
+				{Type: JobTypeOutbound, Name: "outbound3", Status: StatusTypeActive},
+			},
+		},
+		{
+			name: "none active",
+			jobs: []string{"O,outbound2,I"},
+			want: []Job{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond("AGTListJobs", tt.jobs)
+
+			got, err := c.ListActiveJobs(context.Background(), JobTypeOutbound)
+			if err != nil {
+				t.Fatalf("c.ListActiveJobs() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("c.ListActiveJobs() = %#v, want %#v", got, tt.want)
+			}
+
+			commands := srv.Commands()
+			if segments := commands[len(commands)-1].Segments; !reflect.DeepEqual(segments, []string{"O"}) {
+				t.Errorf("command.Segments = %v, want [O]", segments)
+			}
+		})
+	}
+}
+
+func TestClient_ClearNotifyKeyField(t *testing.T) {
+	c, srv, stop := newServerClient(t)
+	defer stop()
+
+	tests := []struct {
+		name     string
+		listType ListType
+		code     string
+		wantErr  error
+	}{
+		{name: "outbound", listType: ListTypeOutbound},
+		{name: "inbound", listType: ListTypeInbound},
+		{name: "no job", listType: ListTypeOutbound, code: "E28913", wantErr: AvayaError{Code: "E28913"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Respond("AGTReqNotKeyField")
+			if tt.code != "" {
+				srv.Fail("AGTReqNotKeyField", tt.code)
+			}
+
+			if err := c.ClearNotifyKeyField(context.Background(), tt.listType); !errors.Is(err, tt.wantErr) {
+				t.Errorf("c.ClearNotifyKeyField() error = %v, want %v", err, tt.wantErr)
+			}
+
+			commands := srv.Commands()
+			if segments := commands[len(commands)-1].Segments; !reflect.DeepEqual(segments, []string{string(tt.listType)}) {
+				t.Errorf("command.Segments = %v, want [%c]", segments, tt.listType)
+			}
+		})
+	}
+}
+
+func TestClient_Logon_ProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		version string
+	}{
+		{name: "default", version: "GOLANG_" + Version},
+		{name: "custom", opts: []Option{WithProtocolVersion("PCAPI_5.1.0.0.4")}, version: "PCAPI_5.1.0.0.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := apctest.NewServer()
+			defer srv.Close()
+
+			c, err := NewClient(srv.Addr, append(tt.opts, WithTlsSkipVerify())...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			defer c.Stop()
+			go func() {
+				_ = c.Start()
+			}()
+
+			if err := c.Logon(context.Background(), "agent1", "secret"); err != nil {
+				t.Fatalf("c.Logon() error = %v", err)
+			}
+
+			commands := srv.Commands()
+			if want := []string{"agent1", "secret", tt.version}; !reflect.DeepEqual(commands[0].Segments, want) {
+				t.Errorf("command.Segments = %v, want %v", commands[0].Segments, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package apc
+
+import "time"
+
+// MetricsHook receives metrics of the Client. It allows to export them to Prometheus or any other system
+// without adding the dependency to this package.
+type MetricsHook interface {
+	// ObserveCommand is called when the command response is processed, err is the result of the command.
+	ObserveCommand(keyword string, duration time.Duration, err error)
+	// IncNotification is called per each received notification.
+	IncNotification(t NotificationType)
+}
+
+// observeCommand starts measuring of the command, returned func reports the result.
+func (c *Client) observeCommand(keyword string) func(err error) {
+	if c.opts.Metrics == nil {
+		return func(error) {}
+	}
+
+	start := time.Now()
+
+	return func(err error) {
+		c.opts.Metrics.ObserveCommand(keyword, time.Since(start), err)
+	}
+}
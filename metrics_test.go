@@ -0,0 +1,49 @@
+package apc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	commands      chan error
+	notifications chan NotificationType
+}
+
+func (m testMetrics) ObserveCommand(keyword string, duration time.Duration, err error) {
+	m.commands <- err
+}
+
+func (m testMetrics) IncNotification(t NotificationType) {
+	m.notifications <- t
+}
+
+func TestClient_observeCommand(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{rawEvent("AGTAttachJob", EventTypeResponse, command.InvokeID, "1", "E28804")}
+	})
+	defer stop()
+
+	metrics := testMetrics{commands: make(chan error, 1), notifications: make(chan NotificationType, 1)}
+	c.opts.Metrics = metrics
+
+	err := c.AttachJob(context.Background(), "outbound1")
+	if got := <-metrics.commands; !errors.Is(got, ErrInvalidJob) || got != err {
+		t.Errorf("ObserveCommand() got error %v, want %v", got, err)
+	}
+
+	_ = c.Subscribe(context.Background())
+
+	c.mu.RLock()
+	r := c.requests[math.MaxUint32]
+	c.mu.RUnlock()
+
+	r.eventChan <- Event{Keyword: "AGTJobEnd", Type: EventTypeNotification, Segments: []string{"0", "M00000"}}
+
+	if got := <-metrics.notifications; got != NotificationTypeJobEnd {
+		t.Errorf("IncNotification() got %v, want %v", got, NotificationTypeJobEnd)
+	}
+}
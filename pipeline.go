@@ -0,0 +1,188 @@
+package apc
+
+import (
+	"context"
+	"fmt"
+)
+
+// Pipeline queues the commands to execute them in order with Do, e.g. to set up the session:
+//
+//	p := client.Pipeline(ctx)
+//	p.Logon("agent1", "secret").ReserveHeadset(1001).ConnectHeadset().AttachJob("outbound1")
+//	if err := p.Do(); err != nil { ... }
+//
+// Pipeline isn't safe for concurrent use.
+type Pipeline struct {
+	c     *Client
+	ctx   context.Context
+	steps []pipelineStep
+}
+
+type pipelineStep struct {
+	name string
+	do   func(ctx context.Context) error
+}
+
+// PipelineError is returned by Pipeline.Do when one of the steps fails.
+type PipelineError struct {
+	// Step is the index of the failed step in the queue, steps before it are completed
+	Step int
+	// Name is the name of the failed step, e.g. AttachJob
+	Name string
+	Err  error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("pipeline step %d (%s) has failed: %v", e.Step, e.Name, e.Err)
+}
+
+// Unwrap returns the error of the failed step.
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline returns the new empty Pipeline, queued commands are executed with ctx.
+func (c *Client) Pipeline(ctx context.Context) *Pipeline {
+	return &Pipeline{c: c, ctx: ctx}
+}
+
+// Do executes queued commands in order and stops at the first error, it's returned as *PipelineError.
+// Executed commands are removed from the queue, so after the failure Do could be called again
+// to retry the failed step and the rest of them.
+func (p *Pipeline) Do() error {
+	for i, step := range p.steps {
+		if err := step.do(p.ctx); err != nil {
+			p.steps = p.steps[i:]
+			return &PipelineError{Step: i, Name: step.name, Err: err}
+		}
+	}
+	p.steps = nil
+
+	return nil
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.steps)
+}
+
+// Then queues custom step, e.g. the command which isn't covered by Pipeline methods.
+func (p *Pipeline) Then(name string, do func(ctx context.Context) error) *Pipeline {
+	p.steps = append(p.steps, pipelineStep{name: name, do: do})
+	return p
+}
+
+// Logon queues Client.Logon.
+func (p *Pipeline) Logon(agentName string, password string) *Pipeline {
+	return p.Then("Logon", func(ctx context.Context) error {
+		return p.c.Logon(ctx, agentName, password)
+	})
+}
+
+// ReserveHeadset queues Client.ReserveHeadset.
+func (p *Pipeline) ReserveHeadset(headsetID int) *Pipeline {
+	return p.Then("ReserveHeadset", func(ctx context.Context) error {
+		return p.c.ReserveHeadset(ctx, headsetID)
+	})
+}
+
+// ConnectHeadset queues Client.ConnectHeadset.
+func (p *Pipeline) ConnectHeadset() *Pipeline {
+	return p.Then("ConnectHeadset", p.c.ConnectHeadset)
+}
+
+// SetWorkClass queues Client.SetWorkClass.
+func (p *Pipeline) SetWorkClass(class WorkClass) *Pipeline {
+	return p.Then("SetWorkClass", func(ctx context.Context) error {
+		return p.c.SetWorkClass(ctx, class)
+	})
+}
+
+// SetUnits queues Client.SetUnits.
+func (p *Pipeline) SetUnits(units ...string) *Pipeline {
+	return p.Then("SetUnits", func(ctx context.Context) error {
+		return p.c.SetUnits(ctx, units...)
+	})
+}
+
+// AttachJob queues Client.AttachJob.
+func (p *Pipeline) AttachJob(jobName string) *Pipeline {
+	return p.Then("AttachJob", func(ctx context.Context) error {
+		return p.c.AttachJob(ctx, jobName)
+	})
+}
+
+// AttachJobWithOptions queues Client.AttachJobWithOptions.
+func (p *Pipeline) AttachJobWithOptions(jobName string, opts JobAttachOptions) *Pipeline {
+	return p.Then("AttachJobWithOptions", func(ctx context.Context) error {
+		return p.c.AttachJobWithOptions(ctx, jobName, opts)
+	})
+}
+
+// SetNotifyKeyField queues Client.SetNotifyKeyField.
+func (p *Pipeline) SetNotifyKeyField(listType ListType, fieldName string) *Pipeline {
+	return p.Then("SetNotifyKeyField", func(ctx context.Context) error {
+		return p.c.SetNotifyKeyField(ctx, listType, fieldName)
+	})
+}
+
+// SetDataField queues Client.SetDataField.
+func (p *Pipeline) SetDataField(listType ListType, fieldName string) *Pipeline {
+	return p.Then("SetDataField", func(ctx context.Context) error {
+		return p.c.SetDataField(ctx, listType, fieldName)
+	})
+}
+
+// SetDataFields queues Client.SetDataFields.
+func (p *Pipeline) SetDataFields(listType ListType, fieldNames ...string) *Pipeline {
+	return p.Then("SetDataFields", func(ctx context.Context) error {
+		return p.c.SetDataFields(ctx, listType, fieldNames...)
+	})
+}
+
+// AvailWork queues Client.AvailWork.
+func (p *Pipeline) AvailWork() *Pipeline {
+	return p.Then("AvailWork", p.c.AvailWork)
+}
+
+// ReadyNextItem queues Client.ReadyNextItem.
+func (p *Pipeline) ReadyNextItem() *Pipeline {
+	return p.Then("ReadyNextItem", p.c.ReadyNextItem)
+}
+
+// ReleaseLine queues Client.ReleaseLine.
+func (p *Pipeline) ReleaseLine() *Pipeline {
+	return p.Then("ReleaseLine", p.c.ReleaseLine)
+}
+
+// FinishedItem queues Client.FinishedItem.
+func (p *Pipeline) FinishedItem(compCode int) *Pipeline {
+	return p.Then("FinishedItem", func(ctx context.Context) error {
+		return p.c.FinishedItem(ctx, compCode)
+	})
+}
+
+// NoFurtherWork queues Client.NoFurtherWork.
+func (p *Pipeline) NoFurtherWork() *Pipeline {
+	return p.Then("NoFurtherWork", p.c.NoFurtherWork)
+}
+
+// DetachJob queues Client.DetachJob.
+func (p *Pipeline) DetachJob() *Pipeline {
+	return p.Then("DetachJob", p.c.DetachJob)
+}
+
+// DisconnectHeadset queues Client.DisconnectHeadset.
+func (p *Pipeline) DisconnectHeadset() *Pipeline {
+	return p.Then("DisconnectHeadset", p.c.DisconnectHeadset)
+}
+
+// FreeHeadset queues Client.FreeHeadset.
+func (p *Pipeline) FreeHeadset() *Pipeline {
+	return p.Then("FreeHeadset", p.c.FreeHeadset)
+}
+
+// Logoff queues Client.Logoff.
+func (p *Pipeline) Logoff() *Pipeline {
+	return p.Then("Logoff", p.c.Logoff)
+}
@@ -0,0 +1,57 @@
+package apc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPipeline_Do(t *testing.T) {
+	var (
+		keywords []string
+		fail     = true
+	)
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords = append(keywords, command.Keyword)
+		if command.Keyword == "AGTAttachJob" && fail {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28804")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	p := c.Pipeline(context.Background())
+	p.Logon("agent1", "secret").ReserveHeadset(1001).AttachJob("outbound1").AvailWork()
+
+	err := p.Do()
+
+	var pipelineErr *PipelineError
+	if !errors.As(err, &pipelineErr) || pipelineErr.Step != 2 || pipelineErr.Name != "AttachJob" {
+		t.Fatalf("p.Do() error = %v, want *PipelineError of AttachJob step", err)
+	}
+
+	if !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("p.Do() error = %v, want %v", err, ErrInvalidJob)
+	}
+
+	if want := []string{"AGTLogon", "AGTReserveHeadset", "AGTAttachJob"}; !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+
+	// The failed step and the rest of them are retried
+	fail = false
+	keywords = nil
+	if p.Len() != 2 {
+		t.Errorf("p.Len() = %v, want 2", p.Len())
+	}
+
+	if err := p.Do(); err != nil {
+		t.Errorf("p.Do() error = %v", err)
+	}
+
+	if want := []string{"AGTAttachJob", "AGTAvailWork"}; !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+}
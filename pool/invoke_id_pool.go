@@ -17,15 +17,23 @@ limitations under the License.
 package pool
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 )
 
+// ErrExhausted is returned by TryGet when all IDs are in use.
+var ErrExhausted = errors.New("all invoke ids are in use")
+
+// MaxInvokeID is the largest invoke ID, protocol limits them to 4 digits.
+const MaxInvokeID = 9999
+
 // InvokeIDPool is used to ensure that the set of IDs in use concurrently never
-// contains any duplicates. The IDs start at 1 and increase without bound, but
+// contains any duplicates. The IDs start at 1 and increase up to MaxInvokeID, but
 // will never be larger than the peak number of concurrent uses.
 //
-// InvokeIDPool's Get() and Release() methods can be used concurrently.
+// InvokeIDPool's Get(), TryGet(), Acquire() and Release() methods can be used concurrently.
 type InvokeIDPool struct {
 	sync.Mutex
 
@@ -33,29 +41,79 @@ type InvokeIDPool struct {
 	used map[uint32]bool
 	// maxUsed remembers the largest value we've given out.
 	maxUsed uint32
+	// max is the largest value we can give out.
+	max uint32
+	// released is closed and replaced when a value is returned with Release().
+	released chan struct{}
 }
 
 // NewInvokeIDPool creates and initializes an IDPool.
 func NewInvokeIDPool() *InvokeIDPool {
+	return newInvokeIDPool(MaxInvokeID)
+}
+
+func newInvokeIDPool(max uint32) *InvokeIDPool {
 	return &InvokeIDPool{
-		used: make(map[uint32]bool),
+		used:     make(map[uint32]bool),
+		max:      max,
+		released: make(chan struct{}),
 	}
 }
 
 // Get returns an ID that is unique among currently active users of this pool.
+// If all IDs are in use, it blocks until one of them is released.
 func (pool *InvokeIDPool) Get() (id uint32) {
+	id, _ = pool.Acquire(context.Background())
+	return id
+}
+
+// TryGet is like Get, but it returns ErrExhausted instead of blocking if all IDs are in use.
+func (pool *InvokeIDPool) TryGet() (uint32, error) {
 	pool.Lock()
 	defer pool.Unlock()
 
+	if id, ok := pool.get(); ok {
+		return id, nil
+	}
+
+	return 0, ErrExhausted
+}
+
+// Acquire is like Get, but it stops waiting for a released ID when ctx is done and returns ctx.Err().
+func (pool *InvokeIDPool) Acquire(ctx context.Context) (uint32, error) {
+	for {
+		pool.Lock()
+		id, ok := pool.get()
+		released := pool.released
+		pool.Unlock()
+
+		if ok {
+			return id, nil
+		}
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// get returns an ID if there is an available one, the pool must be locked.
+func (pool *InvokeIDPool) get() (uint32, bool) {
 	// Pick a value that's been returned, if any.
 	for key := range pool.used {
 		delete(pool.used, key)
-		return key
+		return key, true
+	}
+
+	// No recycled IDs are available, so increase the pool size if possible.
+	if pool.maxUsed < pool.max {
+		pool.maxUsed += 1
+		return pool.maxUsed, true
 	}
 
-	// No recycled IDs are available, so increase the pool size.
-	pool.maxUsed += 1
-	return pool.maxUsed
+	return 0, false
 }
 
 // Release recycles an ID back into the pool for others to use. Releasing back a value
@@ -73,6 +131,10 @@ func (pool *InvokeIDPool) Release(id uint32) {
 		panic(fmt.Errorf("InvokeIDPool.Release(%v): can't release value that was already recycled", id))
 	}
 
+	// Wake up everyone waiting for a released value.
+	close(pool.released)
+	pool.released = make(chan struct{})
+
 	// If we're recycling maxUsed, just shrink the pool.
 	if id == pool.maxUsed {
 		pool.maxUsed = id - 1
@@ -17,9 +17,11 @@ limitations under the License.
 package pool
 
 import (
+	"context"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func (pool *InvokeIDPool) want(want *InvokeIDPool, t *testing.T) {
@@ -128,3 +130,78 @@ func TestInvokeIDPool_ReleaseDuplicate(t *testing.T) {
 	defer wantError("already recycled", t)
 	pool.Release(1)
 }
+
+func TestInvokeIDPool_GetMax(t *testing.T) {
+	pool := NewInvokeIDPool()
+	for i := 0; i < MaxInvokeID; i++ {
+		pool.Get()
+	}
+
+	pool.want(&InvokeIDPool{used: map[uint32]bool{}, maxUsed: MaxInvokeID}, t)
+
+	got := make(chan uint32)
+	go func() {
+		got <- pool.Get()
+	}()
+
+	select {
+	case id := <-got:
+		t.Fatalf("pool.Get() = %v, want to block until release", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pool.Release(42)
+
+	if id := <-got; id != 42 {
+		t.Errorf("pool.Get() = %v, want 42", id)
+	}
+}
+
+func TestInvokeIDPool_TryGet(t *testing.T) {
+	pool := newInvokeIDPool(1)
+
+	if got, err := pool.TryGet(); got != 1 || err != nil {
+		t.Errorf("pool.TryGet() = %v, %v, want 1, nil", got, err)
+	}
+
+	if _, err := pool.TryGet(); err != ErrExhausted {
+		t.Errorf("pool.TryGet() error = %v, want %v", err, ErrExhausted)
+	}
+
+	pool.want(&InvokeIDPool{used: map[uint32]bool{}, maxUsed: 1}, t)
+}
+
+func TestInvokeIDPool_AcquireCancel(t *testing.T) {
+	pool := newInvokeIDPool(1)
+	pool.Get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := pool.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("pool.Acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	pool.want(&InvokeIDPool{used: map[uint32]bool{}, maxUsed: 1}, t)
+}
+
+func TestInvokeIDPool_AcquireRelease(t *testing.T) {
+	pool := newInvokeIDPool(2)
+	pool.Get()
+	pool.Get()
+
+	got := make(chan uint32)
+	for i := 0; i < 2; i++ {
+		go func() {
+			id, _ := pool.Acquire(context.Background())
+			got <- id
+		}()
+	}
+
+	pool.Release(1)
+	pool.Release(2)
+
+	if id1, id2 := <-got, <-got; id1+id2 != 3 {
+		t.Errorf("pool.Acquire() = %v and %v, want 1 and 2", id1, id2)
+	}
+}
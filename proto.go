@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -60,6 +61,11 @@ func (e Event) IsPending() bool {
 	return true
 }
 
+// IsBusy reports whether the server cannot process the command, it's very rare.
+func (e Event) IsBusy() bool {
+	return e.Type == EventTypeBusy
+}
+
 func (e Event) IsDataMessage() bool {
 	if e.Type != EventTypeData ||
 		len(e.Segments) < 2 ||
@@ -126,14 +132,26 @@ func (e Event) IsNotificationData() bool {
 	return true
 }
 
-func encodeCommand(keyword string, invokeID uint32, args ...string) ([]byte, error) {
+func encodeCommand(keyword, client string, processID, invokeID uint32, args ...string) ([]byte, error) {
 	// Checks
 	if len(keyword) > 20 {
 		return nil, errors.New("keyword should be less or equal to 20 bytes")
 	}
+	if len(client) > 20 {
+		return nil, errors.New("client name should be less or equal to 20 bytes")
+	}
+	if len(strconv.Itoa(int(processID))) > 6 {
+		return nil, errors.New("process id should be less or equal to 6 bytes")
+	}
 	if len(strconv.Itoa(int(invokeID))) > 4 {
 		return nil, errors.New("invoke id should be less or equal to 4 bytes")
 	}
+	for i, arg := range args {
+		// Delimiters inside the argument would corrupt the frame
+		if j := strings.IndexAny(arg, string([]byte{RS, ETX, ETB})); j != -1 {
+			return nil, fmt.Errorf("argument %d contains forbidden delimiter byte %#x", i, arg[j])
+		}
+	}
 
 	buf := bytes.NewBuffer(nil)
 
@@ -144,10 +162,10 @@ func encodeCommand(keyword string, invokeID uint32, args ...string) ([]byte, err
 	buf.WriteByte('C')
 
 	// Client; 20 bytes
-	buf.WriteString(fmt.Sprintf("%-20s", "Golang"))
+	buf.WriteString(fmt.Sprintf("%-20s", client))
 
 	// Process ID; 6 bytes
-	buf.WriteString(fmt.Sprintf("%-6d", 0))
+	buf.WriteString(fmt.Sprintf("%-6d", processID))
 
 	// Invoke ID; 4 bytes
 	buf.WriteString(fmt.Sprintf("%-4d", invokeID))
@@ -183,9 +201,22 @@ func IsDecodingError(err error) bool {
 	return ok
 }
 
+// decodeEvent decodes the frame terminated by ETX or ETB. Frame layout is:
+//
+//	[0:20]   keyword, padded with spaces
+//	[20]     event type
+//	[21:41]  client name, padded with spaces
+//	[41:47]  process id
+//	[47:51]  invoke id
+//	[51:55]  number of segments
+//	[55]     RS, it precedes every segment
+//	...      segments separated by RS
+//	[n-1]    ETX or ETB
+//
+// So the shortest frame is 56 bytes long: the header and the terminator without any segments.
 func decodeEvent(raw string) (event Event, err error) {
 	if len(raw) < 56 {
-		return Event{}, newDecodingError("event len should be less or equal to 55 bytes")
+		return Event{}, newDecodingError("event len should be at least 56 bytes")
 	}
 
 	event = Event{
@@ -194,169 +225,833 @@ func decodeEvent(raw string) (event Event, err error) {
 		Client:  strings.TrimSpace(raw[21:41]),
 	}
 
-	processID, err := strconv.Atoi(strings.TrimSpace(raw[41:47]))
+	processID, err := parseHeaderField(raw[41:47])
 	if err != nil {
 		return Event{}, newDecodingError("cannot parse process id as int")
 	}
-	event.ProcessID = uint32(processID)
+	event.ProcessID = processID
 
-	invokeID, err := strconv.Atoi(strings.TrimSpace(raw[47:51]))
+	invokeID, err := parseHeaderField(raw[47:51])
 	if err != nil {
 		return Event{}, newDecodingError("cannot parse invoke id as int")
 	}
-	event.InvokeID = uint32(invokeID)
+	event.InvokeID = invokeID
 
-	numberOfSegments, err := strconv.Atoi(strings.TrimSpace(raw[51:55]))
+	numberOfSegments, err := parseHeaderField(raw[51:55])
 	if err != nil {
 		return Event{}, newDecodingError("cannot parse number of segments as int")
 	}
 
-	if numberOfSegments > 0 && len(raw) > 56 {
-		segments := strings.Split(raw[56:], string(RS))
-		for i, s := range segments {
-			// Trim last byte if it reached the end
-			if i == len(segments)-1 {
-				if strings.HasSuffix(s, string(ETB)) {
-					event.IsIncomplete = true
-					s = strings.TrimSuffix(s, string(ETB))
-				}
+	// Trim the terminator of the frame, ETB means that the rest of data is sent in the next frames
+	switch raw[len(raw)-1] {
+	case ETB:
+		event.IsIncomplete = true
+		raw = raw[:len(raw)-1]
+	case ETX:
+		raw = raw[:len(raw)-1]
+	}
 
-				if strings.HasSuffix(s, string(ETX)) {
-					s = strings.TrimSuffix(s, string(ETX))
-				}
-			}
+	if len(raw) > 55 && raw[55] != RS {
+		return Event{}, newDecodingError("segments should be preceded by RS")
+	}
 
-			event.Segments = append(event.Segments, s)
-		}
+	if numberOfSegments > 0 && len(raw) > 55 {
+		event.Segments = strings.Split(raw[56:], string(RS))
 	}
 
 	return
 }
 
+// parseHeaderField parses the numeric space-padded header field, negative numbers are not allowed.
+func parseHeaderField(field string) (uint32, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(n), nil
+}
+
 type AvayaError struct {
 	Code string
 }
 
+// Error returns the code with its description, e.g. "E28924: must log into system first";
+// unknown codes are returned as is.
 func (e AvayaError) Error() string {
+	if description, ok := avayaErrorDescriptions[e.Code]; ok {
+		return e.Code + ": " + description
+	}
+
 	return e.Code
 }
 
+// Is reports whether the code matches the target sentinel error, so AvayaError could be checked with errors.Is:
+//
+//	if errors.Is(err, apc.ErrNotLoggedOn) { ... }
+//
+// Unknown codes don't match any sentinel error, use Code to check them.
+func (e AvayaError) Is(target error) bool {
+	sentinel, ok := avayaErrors[e.Code]
+	return ok && sentinel == target
+}
+
+// Sentinel errors for the common Avaya error codes.
+var (
+	ErrNotLoggedOn          = errors.New("not logged on")
+	ErrAlreadyLoggedOn      = errors.New("already logged on")
+	ErrInvalidLogon         = errors.New("invalid logon")
+	ErrInvalidJob           = errors.New("job is not running or not available")
+	ErrJobNotAttached       = errors.New("job is not attached")
+	ErrJobAlreadyAttached   = errors.New("job is already attached")
+	ErrNotAvailableForWork  = errors.New("not available for work")
+	ErrNoActiveCall         = errors.New("no active call")
+	ErrNoCustomerRecord     = errors.New("no open customer record")
+	ErrNotManagedJob        = errors.New("attached job is not a managed dialing job")
+	ErrInvalidPhoneNumber   = errors.New("invalid phone number")
+	ErrInvalidHeadset       = errors.New("invalid headset")
+	ErrHeadsetNotConnected  = errors.New("headset is not connected")
+	ErrFieldNotFound        = errors.New("field not found")
+	ErrInvalidCompletion    = errors.New("invalid completion code")
+	ErrFeatureNotAvailable  = errors.New("feature is not available")
+	ErrIncorrectArgumentNum = errors.New("incorrect number of arguments")
+	ErrPasswordExpired      = errors.New("password has expired")
+	ErrPasswordChangeDenied = errors.New("password change is denied")
+	ErrInvalidPassword      = errors.New("invalid password")
+)
+
+// avayaErrorDescriptions maps documented Avaya error codes to their descriptions.
+var avayaErrorDescriptions = map[string]string{
+	"E00518": "calling list does not exist",
+	"E28628": "transfer failed, try again later",
+	"E28800": "recall time is too close to the current time",
+	"E28804": "job is not running",
+	"E28805": "job is not ready, join the job later",
+	"E28812": "agent already logged on, access is denied",
+	"E28813": "maximum agent limit reached",
+	"E28814": "managed agents cannot join this job",
+	"E28815": "sales verification with unit work lists is not permitted",
+	"E28816": "only inbound agents are permitted",
+	"E28817": "only outbound agents are permitted",
+	"E28818": "only outbound or managed agents are permitted",
+	"E28819": "only outbound agents are permitted on a sales verification job",
+	"E28831": "field has non-numeric value",
+	"E28833": "date has an invalid month",
+	"E28834": "date has an invalid year",
+	"E28835": "date has an invalid day",
+	"E28836": "invalid format character found",
+	"E28837": "time has an invalid hour",
+	"E28838": "time has an invalid minute",
+	"E28839": "time has an invalid second",
+	"E28840": "time is not in the correct format",
+	"E28841": "invalid phone",
+	"E28842": "invalid phone number",
+	"E28843": "invalid phone number",
+	"E28847": "date is before the current date",
+	"E28848": "recall time is outside the limits for the time zone",
+	"E28849": "time zone for the record is not known",
+	"E28850": "cannot open channel to operator monitor process",
+	"E28851": "no response from the operator monitor process",
+	"E28858": "agent exceeded the number of agent slots available",
+	"E28859": "agent number returned invalid",
+	"E28862": "fatal error, process terminating",
+	"E28864": "unknown IPC message",
+	"E28865": "unknown command message",
+	"E28866": "telephone line is not available",
+	"E28867": "telephone line is not offhook",
+	"E28868": "recalls are not permitted on inbound calls",
+	"E28869": "headset volume must be set between 1 and 8",
+	"E28870": "reserve headset ID request pending",
+	"E28871": "invalid headset ID",
+	"E28872": "headset is already connected",
+	"E28873": "headset ID is not reserved nor validated",
+	"E28874": "connect headset request is pending",
+	"E28875": "no headset connect request is pending",
+	"E28876": "headset is not connected",
+	"E28877": "disconnect headset request is pending",
+	"E28879": "headset is not disconnected",
+	"E28880": "headset connection is broken",
+	"E28881": "headset reconnected",
+	"E28882": "already available for work, cannot change the agent type",
+	"E28883": "invalid agent type",
+	"E28884": "cannot attach to shared data memory",
+	"E28885": "not attached to a job",
+	"E28886": "unit work lists are not permitted on this job",
+	"E28887": "already available for work, cannot change unit",
+	"E28888": "unit not found",
+	"E28889": "already attached to a job",
+	"E28890": "failure to open job resource file",
+	"E28891": "must specify inbound or outbound operation",
+	"E28892": "no inbound calling list fields are available",
+	"E28893": "no outbound calling list fields are available",
+	"E28894": "field not found",
+	"E28895": "already available for work",
+	"E28896": "headset must be active",
+	"E28897": "available for work request is pending",
+	"E28898": "job is not available",
+	"E28899": "no available for work request is pending",
+	"E28900": "wrong message ID received",
+	"E28901": "not available for work",
+	"E28902": "already have open customer record",
+	"E28903": "already set ready for next customer record",
+	"E28904": "request for no further work is pending",
+	"E28905": "request to transfer to another job is active",
+	"E28906": "not ready for next customer record",
+	"E28907": "attached job is not a managed dialing job",
+	"E28908": "no open customer record",
+	"E28909": "managed dialing call is already complete",
+	"E28910": "managed dialing call is cancelled or complete",
+	"E28911": "managed call already cancelled",
+	"E28912": "customer record is not available for update",
+	"E28913": "there is no attached job to detach",
+	"E28914": "still available for work on the job",
+	"E28915": "not logged out of job",
+	"E28916": "job attached, detach job and retry",
+	"E28917": "no job attached, job linking is not available",
+	"E28918": "not available for work on the job",
+	"E28919": "no active customer record to release",
+	"E28920": "headset ID is not found in reserved list",
+	"E28921": "fatal error, agent process ending",
+	"E28922": "no reserve headset ID request pending",
+	"E28923": "headset ID is already reserved",
+	"E28924": "must log into system first",
+	"E28925": "already logged on to the system",
+	"E28926": "invalid logon",
+	"E28942": "transfer job is not available",
+	"E28946": "predictive blend agent is not acquired for outbound calls",
+	"E28947": "invalid completion code",
+	"E28950": "extension not a valid ACD extension",
+	"E28951": "extension is in use by another agent",
+	"E28952": "maximum number of ACD agents logged on",
+	"E28953": "cannot read file containing ACD extensions",
+	"E28954": "duplicate login",
+	"E28955": "predictive blend dispatcher process is not running",
+	"E28956": "unknown ACD logon error",
+	"E28964": "agent phone is busy, release phone line before proceeding",
+	"E28965": "softdialer link is down",
+	"E28967": "agent is not allowed to logoff",
+	"E29000": "agent type is not managed, cannot join managed dialing job",
+	"E29203": "could not attach slot",
+	"E29206": "failed to attach segment of shared memory",
+	"E29950": "feature is not available",
+	"E50100": "exceeded the maximum number of agents allowed",
+	"E50611": "headset ID is already reserved",
+	"E50612": "no more headsets permitted on the system",
+	"E50613": "failed to access the headset ID file",
+	"E70000": "incorrect number of arguments",
+	"E70001": "incorrect message type",
+	"E70002": "timed out",
+	"E70003": "unknown job type",
+	"E70006": "need to select work unit",
+	"E70007": "cannot transfer an inbound call",
+	"E70008": "must specify a transfer job",
+	"E70009": "unable to send the message",
+	"E70010": "conference call is already in progress",
+	"E70011": "predictive blend is not available on this system",
+	"E70012": "password has expired, must be changed",
+	"E70013": "password can not be changed, change limit not expired",
+	"E70014": "password can not be changed, password file locked",
+	"E70015": "unable to become root privilege for setting password",
+	"E70016": "original password is invalid",
+	"E70017": "new password entered is invalid",
+}
+
+// avayaErrors maps documented Avaya error codes to the sentinel errors.
+var avayaErrors = map[string]error{
+	"E28924": ErrNotLoggedOn,
+	"E28812": ErrAlreadyLoggedOn,
+	"E28925": ErrAlreadyLoggedOn,
+	"E28926": ErrInvalidLogon,
+	"E28804": ErrInvalidJob,
+	"E28805": ErrInvalidJob,
+	"E28898": ErrInvalidJob,
+	"E28885": ErrJobNotAttached,
+	"E28913": ErrJobNotAttached,
+	"E28917": ErrJobNotAttached,
+	"E28889": ErrJobAlreadyAttached,
+	"E28916": ErrJobAlreadyAttached,
+	"E28901": ErrNotAvailableForWork,
+	"E28918": ErrNotAvailableForWork,
+	"E28866": ErrNoActiveCall,
+	"E28867": ErrNoActiveCall,
+	"E28907": ErrNotManagedJob,
+	"E28908": ErrNoCustomerRecord,
+	"E28912": ErrNoCustomerRecord,
+	"E28919": ErrNoCustomerRecord,
+	"E28841": ErrInvalidPhoneNumber,
+	"E28842": ErrInvalidPhoneNumber,
+	"E28843": ErrInvalidPhoneNumber,
+	"E28871": ErrInvalidHeadset,
+	"E28873": ErrInvalidHeadset,
+	"E28920": ErrInvalidHeadset,
+	"E28876": ErrHeadsetNotConnected,
+	"E28880": ErrHeadsetNotConnected,
+	"E28894": ErrFieldNotFound,
+	"E28947": ErrInvalidCompletion,
+	"E29950": ErrFeatureNotAvailable,
+	"E70012": ErrPasswordExpired,
+	"E70013": ErrPasswordChangeDenied,
+	"E70014": ErrPasswordChangeDenied,
+	"E70015": ErrPasswordChangeDenied,
+	"E70016": ErrInvalidPassword,
+	"E70017": ErrInvalidPassword,
+	"E70000": ErrIncorrectArgumentNum,
+}
+
 func processRequest(r *request) ([]string, error) {
+	segments, _, err := processRequestWithResponse(r)
+	return segments, err
+}
+
+// processRequestWithResponse is like processRequest, but it also returns segments of the successful response
+// that follow M00000 code; most commands don't have them.
+func processRequestWithResponse(r *request) ([]string, []string, error) {
+	segments, response, err := readResponse(r)
+	if r.finish != nil {
+		r.finish(err)
+	}
+
+	return segments, response, err
+}
+
+// readResponse reads request events until the response is complete.
+func readResponse(r *request) ([]string, []string, error) {
 	var (
-		dataSegments []string
-		batch        bool
+		dataSegments     []string
+		responseSegments []string
+		batch            bool
+		// pendingTimer fires when the request stays pending too long, it's nil while the request isn't pending
+		pendingTimer *time.Timer
+		// pendingTimeout is the channel of pendingTimer, nil channel never fires
+		pendingTimeout <-chan time.Time
 	)
+	defer func() {
+		if pendingTimer != nil {
+			pendingTimer.Stop()
+		}
+	}()
 el:
 	for {
-		select {
-		case event := <-r.eventChan:
-			switch {
-			// Skip pending events
-			case event.IsPending():
-				continue
-			// Handle data messages and wait successful request
-			case event.IsDataMessage():
-				dataSegments = append(dataSegments, event.Segments[1:]...)
-				// If event is incomplete then mark it as a batch
-				if event.IsIncomplete {
-					batch = true
-				}
-				continue
-			case batch:
-				dataSegments = append(dataSegments, event.Segments...)
-				// If event is complete then unmark it as a batch
-				if !event.IsIncomplete {
-					batch = false
-				}
-				continue
-			// Break the loop in case of success
-			case event.IsSuccessfulResponse():
-				break el
-			// Return error immediately
-			case event.IsResponseError():
-				return nil, AvayaError{Code: event.Segments[1]}
-			default:
-				return nil, fmt.Errorf("unexpected event")
+		event, err := nextEvent(r, pendingTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Any other event means that the server has done with the pending request
+		if !event.IsPending() && pendingTimer != nil {
+			pendingTimer.Stop()
+			pendingTimer, pendingTimeout = nil, nil
+		}
+
+		switch {
+		// Skip pending events, but limit the time of being pending
+		case event.IsPending():
+			if r.maxPending > 0 && pendingTimer == nil {
+				pendingTimer = time.NewTimer(r.maxPending)
+				pendingTimeout = pendingTimer.C
 			}
-		case <-r.context.Done():
-			return nil, r.context.Err()
+			continue
+		// Handle data messages and wait successful request
+		case event.IsDataMessage():
+			dataSegments = append(dataSegments, event.Segments[1:]...)
+			// If event is incomplete then mark it as a batch
+			if event.IsIncomplete {
+				batch = true
+			}
+			continue
+		case batch:
+			dataSegments = append(dataSegments, event.Segments...)
+			// If event is complete then unmark it as a batch
+			if !event.IsIncomplete {
+				batch = false
+			}
+			continue
+		// Break the loop in case of success
+		case event.IsSuccessfulResponse():
+			if len(event.Segments) > 2 {
+				responseSegments = event.Segments[2:]
+			}
+			break el
+		// Server won't process the command, so the caller should back off and retry
+		case event.IsBusy():
+			return nil, nil, ErrServerBusy
+		// Return error immediately
+		case event.IsResponseError():
+			return nil, nil, AvayaError{Code: event.Segments[1]}
+		default:
+			return nil, nil, fmt.Errorf("unexpected event")
 		}
 	}
 
-	return dataSegments, nil
+	return dataSegments, responseSegments, nil
 }
 
+// nextEvent returns the next event of the request. Events received before the request is cancelled are returned
+// first, e.g. AGTLogoff response that stops the Client and cancels all requests right after.
+func nextEvent(r *request, pendingTimeout <-chan time.Time) (Event, error) {
+	select {
+	case event := <-r.eventChan:
+		return event, nil
+	default:
+	}
+
+	select {
+	case event := <-r.eventChan:
+		return event, nil
+	case <-pendingTimeout:
+		return Event{}, ErrPendingTimeout
+	case <-r.context.Done():
+		if err := r.err.Load(); err != nil {
+			return Event{}, err
+		}
+		return Event{}, r.context.Err()
+	}
+}
+
+// Keywords of the commands sent to the server.
+const (
+	cmdAttachJob         = "AGTAttachJob"
+	cmdAvailWork         = "AGTAvailWork"
+	cmdConnHeadset       = "AGTConnHeadset"
+	cmdDetachJob         = "AGTDetachJob"
+	cmdDialDigit         = "AGTDialDigit"
+	cmdDisconnHeadset    = "AGTDisconnHeadset"
+	cmdEchoOff           = "AGTEchoOff"
+	cmdEchoOn            = "AGTEchoOn"
+	cmdFinishedItem      = "AGTFinishedItem"
+	cmdFreeHeadset       = "AGTFreeHeadset"
+	cmdHoldCall          = "AGTHoldCall"
+	cmdListCallFields    = "AGTListCallFields"
+	cmdListCallLists     = "AGTListCallLists"
+	cmdListCallbackFmt   = "AGTListCallbackFmt"
+	cmdListDataFields    = "AGTListDataFields"
+	cmdListJobs          = "AGTListJobs"
+	cmdListKeys          = "AGTListKeys"
+	cmdListState         = "AGTListState"
+	cmdLogIoStart        = "AGTLogIoStart"
+	cmdLogIoStop         = "AGTLogIoStop"
+	cmdLogoff            = "AGTLogoff"
+	cmdLogon             = "AGTLogon"
+	cmdManagedCall       = "AGTManagedCall"
+	cmdManualCall        = "AGTManualCall"
+	cmdNoFurtherWork     = "AGTNoFurtherWork"
+	cmdPreviewRecord     = "AGTPreviewRecord"
+	cmdReadField         = "AGTReadField"
+	cmdReadyNextItem     = "AGTReadyNextItem"
+	cmdReleaseLine       = "AGTReleaseLine"
+	cmdReserveHeadset    = "AGTReserveHeadset"
+	cmdSendMessage       = "AGTSendMessage"
+	cmdSetCallback       = "AGTSetCallback"
+	cmdSetDataField      = "AGTSetDataField"
+	cmdSetNotifyKeyField = "AGTSetNotifyKeyField"
+	cmdSetPassword       = "AGTSetPassword"
+	cmdSetUnit           = "AGTSetUnit"
+	cmdSetWorkClass      = "AGTSetWorkClass"
+	cmdTransferCall      = "AGTTransferCall"
+	cmdUnholdCall        = "AGTUnholdCall"
+	cmdUpdateField       = "AGTUpdateField"
+)
+
+// Keywords of the commands that are not described in Agent API 5.2 guide. Only some server versions support them,
+// the others reply with an error, so methods sending them depend on the server version.
+const (
+	cmdAnswerCall     = "AGTAnswerCall"
+	cmdClearCallback  = "AGTClearCallback"
+	cmdGetAppData     = "AGTGetAppData"
+	cmdPlayMessage    = "AGTPlayMessage"
+	cmdReqDataField   = "AGTReqDataField"
+	cmdReqHeadset     = "AGTReqHeadset"
+	cmdReqNotKeyField = "AGTReqNotKeyField"
+	cmdSetAppData     = "AGTSetAppData"
+	cmdSetCompCode    = "AGTSetCompCode"
+)
+
+// Notification is the parsed notification event. Payload type depends on the notification type,
+// notifications of unknown types carry []string with the segments of their data messages.
 type Notification struct {
 	Type    NotificationType
 	Payload interface{}
 }
 
+// AsCallNotify returns the payload of NotificationTypeCallNotify notification.
+func (n Notification) AsCallNotify() (*CallNotify, bool) {
+	if n.Type != NotificationTypeCallNotify {
+		return nil, false
+	}
+
+	callNotify, ok := n.Payload.(*CallNotify)
+	return callNotify, ok
+}
+
+// AsPreviewRecord returns the payload of NotificationTypePreviewRecord notification.
+func (n Notification) AsPreviewRecord() (*CallNotify, bool) {
+	if n.Type != NotificationTypePreviewRecord {
+		return nil, false
+	}
+
+	callNotify, ok := n.Payload.(*CallNotify)
+	return callNotify, ok
+}
+
+// AsReceiveMessage returns the payload of NotificationTypeReceiveMessage notification.
+func (n Notification) AsReceiveMessage() (*ReceiveMessage, bool) {
+	if n.Type != NotificationTypeReceiveMessage {
+		return nil, false
+	}
+
+	message, ok := n.Payload.(*ReceiveMessage)
+	return message, ok
+}
+
+// AsJobTransRequest returns the name of the job the agent is requested to transfer to
+// by NotificationTypeJobTransRequest notification.
+func (n Notification) AsJobTransRequest() (string, bool) {
+	if n.Type != NotificationTypeJobTransRequest {
+		return "", false
+	}
+
+	jobName, ok := n.Payload.(string)
+	return jobName, ok
+}
+
+// AsJobEnd returns the payload of NotificationTypeJobEnd notification.
+func (n Notification) AsJobEnd() (*JobEnd, bool) {
+	if n.Type != NotificationTypeJobEnd {
+		return nil, false
+	}
+
+	jobEnd, ok := n.Payload.(*JobEnd)
+	return jobEnd, ok
+}
+
+// AsHeadsetConnBroken returns the payload of NotificationTypeHeadsetConnBroken notification.
+func (n Notification) AsHeadsetConnBroken() (*HeadsetConnBroken, bool) {
+	if n.Type != NotificationTypeHeadsetConnBroken {
+		return nil, false
+	}
+
+	broken, ok := n.Payload.(*HeadsetConnBroken)
+	return broken, ok
+}
+
+// AsSystemError returns the payload of NotificationTypeSystemError notification.
+func (n Notification) AsSystemError() (*SystemError, bool) {
+	if n.Type != NotificationTypeSystemError {
+		return nil, false
+	}
+
+	systemErr, ok := n.Payload.(*SystemError)
+	return systemErr, ok
+}
+
+// AsRawEvent returns the payload of NotificationTypeRawEvent notification.
+func (n Notification) AsRawEvent() (Event, bool) {
+	if n.Type != NotificationTypeRawEvent {
+		return Event{}, false
+	}
+
+	event, ok := n.Payload.(Event)
+	return event, ok
+}
+
 type NotificationType string
 
 const (
 	NotificationTypeCallNotify        NotificationType = "AGTCallNotify"
+	NotificationTypePreviewRecord     NotificationType = "AGTPreviewRecord"
 	NotificationTypeAutoReleaseLine   NotificationType = "AGTAutoReleaseLine"
 	NotificationTypeJobEnd            NotificationType = "AGTJobEnd"
 	NotificationTypeReceiveMessage    NotificationType = "AGTReceiveMessage"
 	NotificationTypeJobTransRequest   NotificationType = "AGTJobTransRequest"
 	NotificationTypeHeadsetConnBroken NotificationType = "AGTHeadsetConnBroken"
 	NotificationTypeSystemError       NotificationType = "AGTSystemError"
+	// NotificationTypeRawEvent is the notification event as is, see WithRawNotifications
+	NotificationTypeRawEvent NotificationType = "RawEvent"
 )
 
-func processNotifications(r *request, notifications chan<- Notification) {
+// isKnownNotificationType reports whether notifications of the type are parsed by the Client.
+func isKnownNotificationType(t NotificationType) bool {
+	switch t {
+	case NotificationTypeCallNotify,
+		NotificationTypePreviewRecord,
+		NotificationTypeAutoReleaseLine,
+		NotificationTypeJobEnd,
+		NotificationTypeReceiveMessage,
+		NotificationTypeJobTransRequest,
+		NotificationTypeHeadsetConnBroken,
+		NotificationTypeSystemError:
+		return true
+	}
+
+	return false
+}
+
+// CallNotify is the payload of NotificationTypeCallNotify and NotificationTypePreviewRecord notifications.
+type CallNotify struct {
+	// Message is the operator message containing field information from the customer record
+	Message string
+	// WaitMessage tells how long the customer has been on hold, it's optional
+	WaitMessage string
+	// ListType is the call direction; managed calls are outbound
+	ListType ListType
+	// KeyField and KeyValue are set by SetNotifyKeyField
+	KeyField string
+	KeyValue string
+	// Fields contains data fields requested by SetDataField
+	Fields map[string]string
+}
+
+// newCallNotify parses the first data message of call notification:
+// <OpMesg>[*<WaitMsg>], <CallType>, <NotifyFieldName>,<NotifyFieldData>
+func newCallNotify(segments []string) *CallNotify {
+	n := &CallNotify{
+		ListType: ListTypeOutbound,
+		Fields:   make(map[string]string),
+	}
+
+	if len(segments) > 0 {
+		parts := strings.SplitN(segments[0], "*", 2)
+		n.Message = parts[0]
+		if len(parts) == 2 {
+			n.WaitMessage = parts[1]
+		}
+	}
+
+	if len(segments) > 1 && segments[1] == "INBOUND" {
+		n.ListType = ListTypeInbound
+	}
+
+	if len(segments) > 2 {
+		parts := strings.SplitN(segments[2], ",", 2)
+		n.KeyField = parts[0]
+		if len(parts) == 2 {
+			n.KeyValue = parts[1]
+		}
+	}
+
+	return n
+}
+
+// ReceiveMessage is the payload of NotificationTypeReceiveMessage notification.
+type ReceiveMessage struct {
+	// Text of the message, up to 79 characters
+	Text string
+	// Sender and Time are sent along with supervisor broadcast messages only
+	Sender string
+	Time   string
+	// Broadcast is true for supervisor broadcast messages
+	Broadcast bool
+}
+
+// newReceiveMessage parses the data message of received message: <Text>[, <Sender>[, <Time>]]
+func newReceiveMessage(segments []string) *ReceiveMessage {
+	m := &ReceiveMessage{}
+
+	if len(segments) > 0 {
+		m.Text = segments[0]
+	}
+
+	if len(segments) > 1 {
+		m.Sender = segments[1]
+		m.Broadcast = true
+	}
+
+	if len(segments) > 2 {
+		m.Time = segments[2]
+	}
+
+	return m
+}
+
+// JobEnd is the payload of NotificationTypeJobEnd notification.
+// The agent must detach the job and then log out or attach another one.
+type JobEnd struct {
+	// AttachedJob is the client-side context, not the notification data: AGTJobEnd doesn't carry the job name,
+	// so it's the name of the job attached by AttachJob of this Client when the notification is received.
+	// It's empty if the job was attached otherwise (e.g. with Execute) or the connection was restored since then
+	AttachedJob string
+	// Reason is sent by some server versions only, Agent API 5.2 doesn't distinguish
+	// jobs that end normally and jobs stopped by a supervisor
+	Reason string
+}
+
+// HeadsetConnBroken is the payload of NotificationTypeHeadsetConnBroken notification.
+// After the headset is broken the agent must log out, log in and connect the headset again.
+type HeadsetConnBroken struct {
+	// ReservedHeadset is the client-side context, not the notification data: AGTHeadsetConnBroken doesn't carry
+	// the headset id, so it's the id of the headset reserved by ReserveHeadset of this Client when the notification
+	// is received. It's zero if the headset was reserved otherwise or the connection was restored since then
+	ReservedHeadset int
+	// Reconnected is true when server reports that the headset is reconnected (E28881)
+	Reconnected bool
+	// Err is the error reported by server, e.g. E28880
+	Err AvayaError
+}
+
+// SystemError is the payload of NotificationTypeSystemError notification.
+type SystemError struct {
+	// Code is the error code, e.g. E28862
+	Code string
+	// Message contains data that is part of the error, e.g. "agent,<AgentName>,<slot_number>" for E28859
+	Message string
+	// Fatal is true when the agent session is terminating because of the error
+	Fatal bool
+}
+
+// Error implements error interface.
+func (e *SystemError) Error() string {
+	if e.Message == "" {
+		return AvayaError{Code: e.Code}.Error()
+	}
+
+	return AvayaError{Code: e.Code}.Error() + ": " + e.Message
+}
+
+// Unwrap returns AvayaError with the same code, so errors.Is works with sentinel errors.
+func (e *SystemError) Unwrap() error {
+	return AvayaError{Code: e.Code}
+}
+
+// newSystemError parses system error notification segments: 1, <Code>[,<Data>]
+func newSystemError(segments []string) *SystemError {
+	e := &SystemError{}
+
+	if len(segments) > 1 {
+		parts := strings.SplitN(segments[1], ",", 2)
+		e.Code = parts[0]
+		if len(parts) == 2 {
+			e.Message = parts[1]
+		}
+	}
+
+	// Message text could be split into the following segments
+	if len(segments) > 2 {
+		e.Message = strings.Join(append([]string{e.Message}, segments[2:]...), ",")
+	}
+
+	// Agent child process or the whole system is terminating
+	e.Fatal = e.Code == "E28862" || e.Code == "E28921"
+
+	return e
+}
+
+// processNotifications parses notification events into notifications; with raw each event is also passed as is.
+func processNotifications(r *request, notifications chan<- Notification, raw bool) {
 	var (
-		state   int
-		fields  map[string]string
-		message string
-		jobName string
+		callNotify *CallNotify
+		message    *ReceiveMessage
+		jobName    string
+		jobEnd     = &JobEnd{}
+		// partial is the data message split across ETB frames, it's processed when the last frame is received
+		partial *Event
+		// unknown contains data segments of notifications the Client doesn't parse by keyword
+		unknown = make(map[string][]string)
 	)
 
 	for {
 		select {
 		case event := <-r.eventChan:
+			// Reassemble the data message the same way as processRequest does for the command data
+			if partial != nil {
+				partial.Segments = append(partial.Segments, event.Segments...)
+				if event.IsIncomplete {
+					continue
+				}
+
+				event, partial = *partial, nil
+				event.IsIncomplete = false
+			} else if event.IsIncomplete && event.IsNotificationData() {
+				partial = &event
+				partial.Segments = append([]string(nil), event.Segments...)
+				continue
+			}
+
+			if raw {
+				notifications <- Notification{Type: NotificationTypeRawEvent, Payload: event}
+			}
+
 			switch {
+			// System error contains the data along with the code, so it isn't recognized as the common error
+			case event.Keyword == string(NotificationTypeSystemError) && len(event.Segments) > 1 && event.Segments[0] == "1":
+				notifications <- Notification{Type: NotificationTypeSystemError, Payload: newSystemError(event.Segments)}
 			case event.IsNotificationData():
 				switch NotificationType(event.Keyword) {
-				case NotificationTypeCallNotify:
-					switch state {
-					case 0:
-						state++
-					case 1:
-						fields = make(map[string]string)
-						for _, s := range event.Segments[2:] {
-							parts := strings.Split(s, ",")
-							if len(parts) != 2 {
-								continue
-							}
-
-							fields[parts[0]] = parts[1]
+				// Preview record has the same format as call notification
+				case NotificationTypeCallNotify, NotificationTypePreviewRecord:
+					// The first data message contains call information, the following ones contain data fields
+					if callNotify == nil {
+						callNotify = newCallNotify(event.Segments[2:])
+						continue
+					}
+
+					for _, s := range event.Segments[2:] {
+						parts := strings.SplitN(s, ",", 2)
+						if len(parts) != 2 {
+							continue
 						}
-						state++
+
+						callNotify.Fields[parts[0]] = parts[1]
 					}
 				case NotificationTypeReceiveMessage:
-					message = event.Segments[2]
+					message = newReceiveMessage(event.Segments[2:])
 				case NotificationTypeJobTransRequest:
-					jobName = event.Segments[2]
+					if len(event.Segments) > 2 {
+						jobName = event.Segments[2]
+					}
+				case NotificationTypeJobEnd:
+					if len(event.Segments) > 2 {
+						jobEnd.Reason = event.Segments[2]
+					}
+				default:
+					if !isKnownNotificationType(NotificationType(event.Keyword)) {
+						unknown[event.Keyword] = append(unknown[event.Keyword], event.Segments[2:]...)
+					}
 				}
 			case event.IsSuccessfulNotification():
 				n := Notification{Type: NotificationType(event.Keyword)}
 
 				switch n.Type {
-				case NotificationTypeCallNotify:
-					n.Payload = fields
-					state = 0
-					fields = nil
+				case NotificationTypeCallNotify, NotificationTypePreviewRecord:
+					if callNotify == nil {
+						callNotify = newCallNotify(nil)
+					}
+					n.Payload = callNotify
+					callNotify = nil
 				case NotificationTypeReceiveMessage:
+					if message == nil {
+						message = newReceiveMessage(nil)
+					}
 					n.Payload = message
-					message = ""
+					message = nil
 				case NotificationTypeJobTransRequest:
 					n.Payload = jobName
 					jobName = ""
+				case NotificationTypeJobEnd:
+					n.Payload = jobEnd
+					jobEnd = &JobEnd{}
+				case NotificationTypeHeadsetConnBroken:
+					n.Payload = &HeadsetConnBroken{}
+				default:
+					// Unknown notification is passed with its data as is, so it could be handled by the caller
+					if !isKnownNotificationType(n.Type) {
+						n.Payload = unknown[event.Keyword]
+						delete(unknown, event.Keyword)
+					}
 				}
 
 				notifications <- n
 			case event.IsNotificationError():
-				notifications <- Notification{Type: NotificationType(event.Keyword), Payload: event.Segments[1]}
+				n := Notification{Type: NotificationType(event.Keyword), Payload: event.Segments[1]}
+
+				switch n.Type {
+				case NotificationTypeHeadsetConnBroken:
+					n.Payload = &HeadsetConnBroken{
+						Reconnected: event.Segments[1] == "E28881",
+						Err:         AvayaError{Code: event.Segments[1]},
+					}
+				}
+
+				notifications <- n
 			}
 		case <-r.context.Done():
 			return
@@ -0,0 +1,395 @@
+package apc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rawEvent encodes the event the same way as APC server does.
+func rawEvent(keyword string, eventType EventType, invokeID uint32, segments ...string) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("%-20s", keyword))
+	b.WriteByte(byte(eventType))
+	b.WriteString(fmt.Sprintf("%-20s", "Agent server"))
+	b.WriteString(fmt.Sprintf("%-6d", 2570))
+	b.WriteString(fmt.Sprintf("%-4d", invokeID))
+	b.WriteString(fmt.Sprintf("%-4d", len(segments)))
+	for _, s := range segments {
+		b.WriteByte(RS)
+		b.WriteString(s)
+	}
+	b.WriteByte(ETX)
+
+	return b.String()
+}
+
+// notify runs processNotifications over the passed events and returns the first emitted notification.
+func notify(t *testing.T, events ...Event) Notification {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := newRequest(ctx, 0)
+	notifications := make(chan Notification, 1)
+	go processNotifications(r, notifications, false)
+
+	for _, event := range events {
+		r.eventChan <- event
+	}
+
+	return <-notifications
+}
+
+func TestProcessNotifications_CallNotify(t *testing.T) {
+	n := notify(t,
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00001", "JOHN DOE*00:15", "OUTBOUND", "ACCTNUM,12345"}},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00001", "NAME,JOHN DOE", "CURPHONE,01"}},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00001", "BALANCE,1,000.00"}},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00000"}},
+	)
+
+	if n.Type != NotificationTypeCallNotify {
+		t.Fatalf("n.Type = %v, want %v", n.Type, NotificationTypeCallNotify)
+	}
+
+	want := &CallNotify{
+		Message:     "JOHN DOE",
+		WaitMessage: "00:15",
+		ListType:    ListTypeOutbound,
+		KeyField:    "ACCTNUM",
+		KeyValue:    "12345",
+		Fields: map[string]string{
+			"NAME":     "JOHN DOE",
+			"CURPHONE": "01",
+			"BALANCE":  "1,000.00",
+		},
+	}
+	if got := n.Payload; !reflect.DeepEqual(got, want) {
+		t.Errorf("n.Payload = %#v, want %#v", got, want)
+	}
+}
+
+func TestProcessNotifications_CallNotify_Incomplete(t *testing.T) {
+	n := notify(t,
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00001", "JOHN DOE*00:15", "OUTBOUND"}, IsIncomplete: true},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"ACCTNUM,12345"}},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00001", "NAME,JOHN DOE"}, IsIncomplete: true},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"CURPHONE,01"}, IsIncomplete: true},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"BALANCE,1,000.00"}},
+		Event{Keyword: "AGTCallNotify", Type: EventTypeNotification, Segments: []string{"0", "M00000"}},
+	)
+
+	want := &CallNotify{
+		Message:     "JOHN DOE",
+		WaitMessage: "00:15",
+		ListType:    ListTypeOutbound,
+		KeyField:    "ACCTNUM",
+		KeyValue:    "12345",
+		Fields: map[string]string{
+			"NAME":     "JOHN DOE",
+			"CURPHONE": "01",
+			"BALANCE":  "1,000.00",
+		},
+	}
+	if got := n.Payload; !reflect.DeepEqual(got, want) {
+		t.Errorf("n.Payload = %#v, want %#v", got, want)
+	}
+}
+
+func TestProcessNotifications_ReceiveMessage(t *testing.T) {
+	tests := []struct {
+		segments []string
+		want     *ReceiveMessage
+	}{
+		{
+			segments: []string{"0", "M00001", "Take a break"},
+			want:     &ReceiveMessage{Text: "Take a break"},
+		},
+		{
+			segments: []string{"0", "M00001", "Meeting at 5 PM", "supervisor1", "2020/05/14 16:30:00"},
+			want:     &ReceiveMessage{Text: "Meeting at 5 PM", Sender: "supervisor1", Time: "2020/05/14 16:30:00", Broadcast: true},
+		},
+		{
+			segments: []string{"0", "M00001"},
+			want:     &ReceiveMessage{},
+		},
+	}
+
+	for _, tt := range tests {
+		n := notify(t,
+			Event{Keyword: "AGTReceiveMessage", Type: EventTypeNotification, Segments: tt.segments},
+			Event{Keyword: "AGTReceiveMessage", Type: EventTypeNotification, Segments: []string{"0", "M00000"}},
+		)
+
+		if got := n.Payload; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("n.Payload = %#v, want %#v", got, tt.want)
+		}
+	}
+}
+
+func TestProcessNotifications_HeadsetConnBroken(t *testing.T) {
+	n := notify(t, Event{Keyword: "AGTHeadsetConnBroken", Type: EventTypeNotification, Segments: []string{"1", "E28880"}})
+
+	want := &HeadsetConnBroken{Err: AvayaError{Code: "E28880"}}
+	if got := n.Payload; !reflect.DeepEqual(got, want) {
+		t.Errorf("n.Payload = %#v, want %#v", got, want)
+	}
+
+	if !errors.Is(n.Payload.(*HeadsetConnBroken).Err, ErrHeadsetNotConnected) {
+		t.Errorf("Err = %v, want %v", n.Payload.(*HeadsetConnBroken).Err, ErrHeadsetNotConnected)
+	}
+}
+
+func TestProcessNotifications_SystemError(t *testing.T) {
+	n := notify(t, Event{Keyword: "AGTSystemError", Type: EventTypeNotification, Segments: []string{"1", "E28859,agent,john,5"}})
+
+	want := &SystemError{Code: "E28859", Message: "agent,john,5"}
+	if got := n.Payload; !reflect.DeepEqual(got, want) {
+		t.Errorf("n.Payload = %#v, want %#v", got, want)
+	}
+
+	n = notify(t, Event{Keyword: "AGTSystemError", Type: EventTypeNotification, Segments: []string{"1", "E28921"}})
+	if got := n.Payload.(*SystemError); !got.Fatal {
+		t.Errorf("n.Payload.Fatal = %v, want true", got.Fatal)
+	}
+}
+
+func TestProcessNotifications_ShortSegments(t *testing.T) {
+	types := []NotificationType{
+		NotificationTypeCallNotify,
+		NotificationTypePreviewRecord,
+		NotificationTypeAutoReleaseLine,
+		NotificationTypeJobEnd,
+		NotificationTypeReceiveMessage,
+		NotificationTypeJobTransRequest,
+		NotificationTypeHeadsetConnBroken,
+		NotificationTypeSystemError,
+	}
+
+	for _, typ := range types {
+		keyword := string(typ)
+
+		n := notify(t,
+			Event{Keyword: keyword, Type: EventTypeNotification},
+			Event{Keyword: keyword, Type: EventTypeNotification, Segments: []string{"0"}},
+			Event{Keyword: keyword, Type: EventTypeNotification, Segments: []string{"1"}},
+			Event{Keyword: keyword, Type: EventTypeNotification, Segments: []string{"0", "M00001"}},
+			Event{Keyword: keyword, Type: EventTypeNotification, Segments: []string{"0", "M00000"}},
+		)
+
+		if n.Type != typ {
+			t.Errorf("n.Type = %v, want %v", n.Type, typ)
+		}
+	}
+}
+
+func TestReadResponse_PendingRestarted(t *testing.T) {
+	r := newRequest(context.Background(), 0)
+	defer r.cancel()
+	r.maxPending = 100 * time.Millisecond
+
+	pending := Event{Keyword: "AGTListJobs", Type: EventTypePending, Segments: []string{"0", "S28833"}}
+	go func() {
+		// Each pending period is shorter than maxPending, but the total one is longer
+		r.eventChan <- pending
+		time.Sleep(60 * time.Millisecond)
+		r.eventChan <- Event{Keyword: "AGTListJobs", Type: EventTypeData, Segments: []string{"0", "M00001", "O,outbound1,A"}}
+		r.eventChan <- pending
+		time.Sleep(60 * time.Millisecond)
+		r.eventChan <- Event{Keyword: "AGTListJobs", Type: EventTypeResponse, Segments: []string{"0", "M00000"}}
+	}()
+
+	segments, _, err := readResponse(r)
+	if err != nil {
+		t.Fatalf("readResponse() error = %v", err)
+	}
+
+	if want := []string{"M00001", "O,outbound1,A"}; !reflect.DeepEqual(segments, want) {
+		t.Errorf("readResponse() = %v, want %v", segments, want)
+	}
+}
+
+func TestEncodeCommand_Delimiters(t *testing.T) {
+	for _, b := range []byte{RS, ETX, ETB} {
+		if _, err := encodeCommand("AGTSetDataField", "Golang", 0, 1, "O", "NAME"+string(b)+"PHONE"); err == nil {
+			t.Errorf("encodeCommand() with %#x byte error = nil, want error", b)
+		}
+	}
+
+	if _, err := encodeCommand("AGTSetDataField", "Golang", 0, 1, "O", "NAME"); err != nil {
+		t.Errorf("encodeCommand() error = %v", err)
+	}
+}
+
+func TestAvayaError_Is(t *testing.T) {
+	tests := []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{err: AvayaError{Code: "E28924"}, target: ErrNotLoggedOn, want: true},
+		{err: fmt.Errorf("wrapped: %w", AvayaError{Code: "E28866"}), target: ErrNoActiveCall, want: true},
+		{err: AvayaError{Code: "E28866"}, target: ErrNotLoggedOn, want: false},
+		{err: AvayaError{Code: "E99999"}, target: ErrNotLoggedOn, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := errors.Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestAvayaError_Error(t *testing.T) {
+	if got, want := (AvayaError{Code: "E28924"}).Error(), "E28924: must log into system first"; got != want {
+		t.Errorf("AvayaError.Error() = %v, want %v", got, want)
+	}
+
+	if got, want := (AvayaError{Code: "E99999"}).Error(), "E99999"; got != want {
+		t.Errorf("AvayaError.Error() = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeEvent_Truncated(t *testing.T) {
+	raw := rawEvent("AGTListJobs", EventTypeData, 18, "0", "M00001", "O,outbnd,A")
+
+	for i := 0; i < len(raw); i++ {
+		_, err := decodeEvent(raw[:i])
+		if i < 56 && !IsDecodingError(err) {
+			t.Errorf("decodeEvent(raw[:%d]) error = %v, want decoding error", i, err)
+		}
+	}
+}
+
+func TestDecodeEvent_MalformedHeader(t *testing.T) {
+	raw := rawEvent("AGTListJobs", EventTypeData, 18, "0", "M00001", "O,outbnd,A")
+
+	tests := []struct {
+		name  string
+		field string
+		start int
+	}{
+		{name: "process id", field: "abcdef", start: 41},
+		{name: "negative invoke id", field: "-1  ", start: 47},
+		{name: "empty invoke id", field: "    ", start: 47},
+		{name: "negative number of segments", field: "-3  ", start: 51},
+	}
+
+	for _, tt := range tests {
+		malformed := raw[:tt.start] + tt.field + raw[tt.start+len(tt.field):]
+		if _, err := decodeEvent(malformed); !IsDecodingError(err) {
+			t.Errorf("%s: decodeEvent() error = %v, want decoding error", tt.name, err)
+		}
+	}
+}
+
+func TestDecodeEvent_Boundary(t *testing.T) {
+	header := rawEvent("AGTEchoOn", EventTypeResponse, 1)
+	header = header[:len(header)-1]
+	if len(header) != 55 {
+		t.Fatalf("len(header) = %v, want 55", len(header))
+	}
+
+	if _, err := decodeEvent(header); !IsDecodingError(err) {
+		t.Errorf("decodeEvent(55 bytes) error = %v, want decoding error", err)
+	}
+
+	event, err := decodeEvent(header + string(ETX))
+	if err != nil {
+		t.Fatalf("decodeEvent(56 bytes) error = %v", err)
+	}
+	if event.Keyword != "AGTEchoOn" || len(event.Segments) != 0 {
+		t.Errorf("decodeEvent(56 bytes) = %#v, want AGTEchoOn event without segments", event)
+	}
+
+	raw := rawEvent("AGTEchoOn", EventTypeResponse, 1, "0")
+	if len(raw) != 58 {
+		t.Fatalf("len(raw) = %v, want 58", len(raw))
+	}
+
+	event, err = decodeEvent(raw)
+	if err != nil {
+		t.Fatalf("decodeEvent(58 bytes) error = %v", err)
+	}
+	if !reflect.DeepEqual(event.Segments, []string{"0"}) {
+		t.Errorf("event.Segments = %#v, want %#v", event.Segments, []string{"0"})
+	}
+}
+
+func TestDecodeEvent_Separator(t *testing.T) {
+	raw := rawEvent("AGTListJobs", EventTypeData, 18, "0", "M00001")
+
+	for _, b := range []byte{' ', ',', ETB, '0'} {
+		malformed := raw[:55] + string(b) + raw[56:]
+		if _, err := decodeEvent(malformed); !IsDecodingError(err) {
+			t.Errorf("decodeEvent() with %#x separator error = %v, want decoding error", b, err)
+		}
+	}
+}
+
+func TestNotification_As(t *testing.T) {
+	callNotify := &CallNotify{Message: "CALL"}
+	n := Notification{Type: NotificationTypeCallNotify, Payload: callNotify}
+
+	if got, ok := n.AsCallNotify(); !ok || got != callNotify {
+		t.Errorf("n.AsCallNotify() = %v, %v, want %v, true", got, ok, callNotify)
+	}
+
+	if got, ok := n.AsPreviewRecord(); ok || got != nil {
+		t.Errorf("n.AsPreviewRecord() = %v, %v, want nil, false", got, ok)
+	}
+
+	if _, ok := n.AsSystemError(); ok {
+		t.Errorf("n.AsSystemError() ok = true, want false")
+	}
+
+	n = Notification{Type: NotificationTypeJobTransRequest, Payload: "inbound1"}
+	if got, ok := n.AsJobTransRequest(); !ok || got != "inbound1" {
+		t.Errorf("n.AsJobTransRequest() = %v, %v, want inbound1, true", got, ok)
+	}
+
+	// Error notifications carry the error code instead of the payload
+	n = Notification{Type: NotificationTypeJobEnd, Payload: "E28800"}
+	if got, ok := n.AsJobEnd(); ok || got != nil {
+		t.Errorf("n.AsJobEnd() = %v, %v, want nil, false", got, ok)
+	}
+}
+
+func TestProcessNotifications_Raw(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := newRequest(ctx, 0)
+	notifications := make(chan Notification, 2)
+	go processNotifications(r, notifications, true)
+
+	event := Event{Keyword: "AGTNewFeature", Type: EventTypeNotification, Segments: []string{"0", "M00000"}}
+	r.eventChan <- event
+
+	n := <-notifications
+	if got, ok := n.AsRawEvent(); !ok || !reflect.DeepEqual(got, event) {
+		t.Errorf("n.AsRawEvent() = %#v, %v, want %#v, true", got, ok, event)
+	}
+}
+
+func TestProcessNotifications_Unknown(t *testing.T) {
+	n := notify(t,
+		Event{Keyword: "AGTCustomAlert", Type: EventTypeNotification, Segments: []string{"0", "M00001", "5551234", "ANSWERED"}},
+		Event{Keyword: "AGTCustomAlert", Type: EventTypeNotification, Segments: []string{"0", "M00000"}},
+	)
+
+	if n.Type != "AGTCustomAlert" {
+		t.Fatalf("n.Type = %v, want AGTCustomAlert", n.Type)
+	}
+
+	if want := []string{"5551234", "ANSWERED"}; !reflect.DeepEqual(n.Payload, want) {
+		t.Errorf("n.Payload = %#v, want %#v", n.Payload, want)
+	}
+}
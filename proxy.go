@@ -0,0 +1,128 @@
+package apc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// dialContextFunc adapts the dial function to proxy.Dialer and proxy.ContextDialer interfaces.
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialContextFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(context.Background(), network, addr)
+}
+
+func (f dialContextFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// proxyDialContext returns the dial function that connects to addresses through the proxy,
+// the proxy itself is reached with forward.
+func proxyDialContext(proxyURL string, forward dialContextFunc) (dialContextFunc, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing proxy URL: %w", err)
+	}
+
+	if u.Scheme == "http" {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return httpConnect(ctx, u, forward, network, addr)
+		}, nil
+	}
+
+	dialer, err := proxy.FromURL(u, forward)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating proxy dialer: %w", err)
+	}
+
+	if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+		return contextDialer.DialContext, nil
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.Dial(network, addr)
+	}, nil
+}
+
+// httpConnect establishes the tunnel to addr with HTTP CONNECT method of the proxy.
+func httpConnect(ctx context.Context, u *url.URL, forward dialContextFunc, network, addr string) (net.Conn, error) {
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		proxyAddr = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	conn, err := forward(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Interrupt the exchange with the proxy when the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error while sending CONNECT request: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error while reading CONNECT response: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy has refused CONNECT request: %s", resp.Status)
+	}
+
+	if ctx.Err() != nil {
+		_ = conn.Close()
+		return nil, ctx.Err()
+	}
+
+	// Bytes of the server read ahead with the response shouldn't be lost
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn is the connection that is read through the buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
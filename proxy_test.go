@@ -0,0 +1,98 @@
+package apc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/L11R/go-apc/apctest"
+)
+
+// newConnectProxy starts HTTP proxy supporting CONNECT method only, it returns the address of the proxy
+// and the channel with Proxy-Authorization headers of the accepted requests. The caller should call returned func when finished.
+func newConnectProxy(t *testing.T) (string, <-chan string, func()) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	auth := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				auth <- req.Header.Get("Proxy-Authorization")
+
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+
+				if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+					return
+				}
+
+				go func() {
+					_, _ = io.Copy(target, conn)
+				}()
+				_, _ = io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), auth, func() {
+		_ = listener.Close()
+	}
+}
+
+func TestNewClient_Proxy(t *testing.T) {
+	srv := apctest.NewServer()
+	defer srv.Close()
+
+	proxyAddr, auth, closeProxy := newConnectProxy(t)
+	defer closeProxy()
+
+	c, err := NewClient(srv.Addr, WithTlsSkipVerify(), WithProxy("http://user:secret@"+proxyAddr))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Stop()
+
+	go func() {
+		_ = c.Start()
+	}()
+
+	if err := c.AvailWork(context.Background()); err != nil {
+		t.Errorf("c.AvailWork() error = %v", err)
+	}
+
+	// base64 of "user:secret"
+	if got, want := <-auth, "Basic dXNlcjpzZWNyZXQ="; got != want {
+		t.Errorf("Proxy-Authorization = %v, want %v", got, want)
+	}
+}
+
+func TestNewClient_Proxy_UnknownScheme(t *testing.T) {
+	_, err := NewClient("apc.example.com:22700", WithProxy("ftp://proxy.example.com"))
+	if err == nil || !strings.Contains(err.Error(), "unknown scheme") {
+		t.Errorf("NewClient() error = %v, want unknown scheme error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package apc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SessionConfig describes the agent session started by RunSession.
+type SessionConfig struct {
+	AgentName string
+	Password  string
+	HeadsetID int
+	JobName   string
+	// ListType is the calling list type of DataFields
+	ListType ListType
+	// DataFields are included with call notifications, they are registered by SetDataFields
+	DataFields []string
+}
+
+// sessionStep is the step of the session and the keyword of the teardown step that undoes it, if any.
+type sessionStep struct {
+	do   func(ctx context.Context) error
+	undo string
+}
+
+// sessionRollbackTimeout limits the rollback of the session that has failed to start.
+const sessionRollbackTimeout = 10 * time.Second
+
+// RunSession performs the canonical sequence of commands to get calls: Logon, ReserveHeadset, ConnectHeadset,
+// AttachJob, SetDataFields and AvailWork. If any step fails, the completed ones are undone the same way as Teardown
+// does it and the error of the failed step is returned. The rollback doesn't use ctx, it could be already done,
+// so it's limited by its own 10 seconds timeout. Returned func undoes all steps as well, it should be
+// called to end the session: NoFurtherWork, DetachJob, DisconnectHeadset, FreeHeadset and Logoff;
+// its errors are returned as *TeardownError.
+func (c *Client) RunSession(ctx context.Context, cfg SessionConfig) (func(ctx context.Context) error, error) {
+	steps := []sessionStep{
+		{
+			do: func(ctx context.Context) error {
+				return c.Logon(ctx, cfg.AgentName, cfg.Password)
+			},
+			undo: cmdLogoff,
+		},
+		{
+			do: func(ctx context.Context) error {
+				return c.ReserveHeadset(ctx, cfg.HeadsetID)
+			},
+			undo: cmdFreeHeadset,
+		},
+		{
+			do:   c.ConnectHeadset,
+			undo: cmdDisconnHeadset,
+		},
+		{
+			do: func(ctx context.Context) error {
+				return c.AttachJob(ctx, cfg.JobName)
+			},
+			undo: cmdDetachJob,
+		},
+		{
+			do: func(ctx context.Context) error {
+				return c.SetDataFields(ctx, cfg.ListType, cfg.DataFields...)
+			},
+		},
+		{
+			do:   c.AvailWork,
+			undo: cmdNoFurtherWork,
+		},
+	}
+
+	// Keywords of the teardown steps undoing the completed steps
+	completed := make(map[string]bool, len(steps))
+	undo := func(ctx context.Context) error {
+		var undoSteps []teardownStep
+		for _, step := range c.teardownSteps() {
+			if completed[step.keyword] {
+				undoSteps = append(undoSteps, step)
+			}
+		}
+
+		return c.teardown(ctx, undoSteps)
+	}
+
+	for _, step := range steps {
+		if err := step.do(ctx); err != nil {
+			rollbackCtx, cancel := context.WithTimeout(context.Background(), sessionRollbackTimeout)
+			if err := undo(rollbackCtx); err != nil {
+				c.logger.log(newLogEntry(LogLevelError, "Error while rolling back the session!", map[string]interface{}{"error": err}))
+			}
+			cancel()
+
+			return nil, err
+		}
+
+		if step.undo != "" {
+			completed[step.undo] = true
+		}
+	}
+
+	return undo, nil
+}
+
+// TeardownError is returned by Teardown when some of the commands fail, errors are in order of the commands.
+type TeardownError struct {
+	Errors []error
+}
+
+func (e *TeardownError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return "cannot tear down the session: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target.
+func (e *TeardownError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// teardownStep is the command ending the session.
+type teardownStep struct {
+	keyword string
+	do      func(ctx context.Context) error
+	// codes that mean there is nothing to undo
+	done []string
+}
+
+// teardownSteps returns the commands ending the session in the correct order.
+func (c *Client) teardownSteps() []teardownStep {
+	return []teardownStep{
+		{keyword: cmdNoFurtherWork, do: c.NoFurtherWork, done: []string{"E28917", "E28918"}},
+		{keyword: cmdDetachJob, do: c.DetachJob, done: []string{"E28913"}},
+		{keyword: cmdDisconnHeadset, do: c.DisconnectHeadset, done: []string{"E28876"}},
+		{keyword: cmdFreeHeadset, do: c.FreeHeadset, done: []string{"E28873"}},
+		{keyword: cmdLogoff, do: c.Logoff, done: []string{"E28924"}},
+	}
+}
+
+// Teardown ends the agent session in the correct order: NoFurtherWork, DetachJob, DisconnectHeadset, FreeHeadset
+// and Logoff. Errors meaning that the step is already done (e.g. no job is attached) are ignored, the rest of them
+// don't stop the teardown and are returned as *TeardownError.
+func (c *Client) Teardown(ctx context.Context) error {
+	return c.teardown(ctx, c.teardownSteps())
+}
+
+// teardown executes the steps in order, see Teardown.
+func (c *Client) teardown(ctx context.Context, steps []teardownStep) error {
+	var errs []error
+	for _, step := range steps {
+		err := step.do(ctx)
+		if err == nil {
+			continue
+		}
+
+		var avayaErr AvayaError
+		if errors.As(err, &avayaErr) && containsCode(step.done, avayaErr.Code) {
+			continue
+		}
+
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return &TeardownError{Errors: errs}
+	}
+
+	return nil
+}
+
+func containsCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
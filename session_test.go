@@ -0,0 +1,130 @@
+package apc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClient_RunSession(t *testing.T) {
+	var keywords []string
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords = append(keywords, command.Keyword)
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	end, err := c.RunSession(context.Background(), SessionConfig{
+		AgentName:  "agent1",
+		Password:   "secret",
+		HeadsetID:  1001,
+		JobName:    "outbound1",
+		ListType:   ListTypeOutbound,
+		DataFields: []string{"NAME"},
+	})
+	if err != nil {
+		t.Fatalf("c.RunSession() error = %v", err)
+	}
+
+	if err := end(context.Background()); err != nil {
+		t.Errorf("end() error = %v", err)
+	}
+
+	want := []string{
+		"AGTLogon", "AGTReserveHeadset", "AGTConnHeadset", "AGTAttachJob", "AGTSetDataField", "AGTAvailWork",
+		"AGTNoFurtherWork", "AGTDetachJob", "AGTDisconnHeadset", "AGTFreeHeadset", "AGTLogoff",
+	}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+}
+
+func TestClient_RunSession_Rollback(t *testing.T) {
+	var keywords []string
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords = append(keywords, command.Keyword)
+		if command.Keyword == "AGTAttachJob" {
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28804")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	_, err := c.RunSession(context.Background(), SessionConfig{AgentName: "agent1", Password: "secret", HeadsetID: 1001, JobName: "outbound1"})
+	if !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("c.RunSession() error = %v, want %v", err, ErrInvalidJob)
+	}
+
+	want := []string{"AGTLogon", "AGTReserveHeadset", "AGTConnHeadset", "AGTAttachJob", "AGTDisconnHeadset", "AGTFreeHeadset", "AGTLogoff"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+}
+
+func TestClient_RunSession_RollbackExpired(t *testing.T) {
+	var keywords []string
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords = append(keywords, command.Keyword)
+		switch command.Keyword {
+		// Server doesn't respond in time, so the context of the session expires
+		case "AGTAttachJob":
+			return nil
+		// Headset is already disconnected, it doesn't stop the rollback
+		case "AGTDisconnHeadset":
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28876")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := c.RunSession(ctx, SessionConfig{AgentName: "agent1", Password: "secret", HeadsetID: 1001, JobName: "outbound1"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("c.RunSession() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	want := []string{"AGTLogon", "AGTReserveHeadset", "AGTConnHeadset", "AGTAttachJob", "AGTDisconnHeadset", "AGTFreeHeadset", "AGTLogoff"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+}
+
+func TestClient_Teardown(t *testing.T) {
+	var keywords []string
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords = append(keywords, command.Keyword)
+		switch command.Keyword {
+		case "AGTNoFurtherWork":
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28918")}
+		case "AGTDetachJob":
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28913")}
+		case "AGTFreeHeadset":
+			return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "1", "E28879")}
+		}
+
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	err := c.Teardown(context.Background())
+
+	var teardownErr *TeardownError
+	if !errors.As(err, &teardownErr) || len(teardownErr.Errors) != 1 {
+		t.Fatalf("c.Teardown() error = %v, want *TeardownError with FreeHeadset error", err)
+	}
+
+	if want := (AvayaError{Code: "E28879"}); !errors.Is(err, want) {
+		t.Errorf("c.Teardown() error = %v, want %v", err, want)
+	}
+
+	want := []string{"AGTNoFurtherWork", "AGTDetachJob", "AGTDisconnHeadset", "AGTFreeHeadset", "AGTLogoff"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+}
@@ -0,0 +1,362 @@
+package apc
+
+import "context"
+
+// SimpleClient wraps the Client to call its commands without context, e.g. in scripts and CLI tools.
+// Commands are executed with context.Background(), so they are limited by the timeout configured
+// with WithCommandTimeout only; without it a command waits for the response as long as the connection is alive.
+type SimpleClient struct {
+	c *Client
+}
+
+// Simple returns the SimpleClient working over c.
+func (c *Client) Simple() *SimpleClient {
+	return &SimpleClient{c: c}
+}
+
+// Client returns the underlying Client, e.g. to call the commands with own context.
+func (s *SimpleClient) Client() *Client {
+	return s.c
+}
+
+// Notifications is like Client.Notifications, but the channel lasts until the Client is stopped.
+func (s *SimpleClient) Notifications() <-chan Notification {
+	return s.c.Notifications(context.Background())
+}
+
+// Logon is like Client.Logon, but without context.
+func (s *SimpleClient) Logon(agentName string, password string) error {
+	return s.c.Logon(context.Background(), agentName, password)
+}
+
+// ReserveHeadset is like Client.ReserveHeadset, but without context.
+func (s *SimpleClient) ReserveHeadset(headsetID int) error {
+	return s.c.ReserveHeadset(context.Background(), headsetID)
+}
+
+// ConnectHeadset is like Client.ConnectHeadset, but without context.
+func (s *SimpleClient) ConnectHeadset() error {
+	return s.c.ConnectHeadset(context.Background())
+}
+
+// AttachJob is like Client.AttachJob, but without context.
+func (s *SimpleClient) AttachJob(jobName string) error {
+	return s.c.AttachJob(context.Background(), jobName)
+}
+
+// SetDataField is like Client.SetDataField, but without context.
+func (s *SimpleClient) SetDataField(listType ListType, fieldName string) error {
+	return s.c.SetDataField(context.Background(), listType, fieldName)
+}
+
+// AvailWork is like Client.AvailWork, but without context.
+func (s *SimpleClient) AvailWork() error {
+	return s.c.AvailWork(context.Background())
+}
+
+// ReadyNextItem is like Client.ReadyNextItem, but without context.
+func (s *SimpleClient) ReadyNextItem() error {
+	return s.c.ReadyNextItem(context.Background())
+}
+
+// FinishedItem is like Client.FinishedItem, but without context.
+func (s *SimpleClient) FinishedItem(compCode int) error {
+	return s.c.FinishedItem(context.Background(), compCode)
+}
+
+// NoFurtherWork is like Client.NoFurtherWork, but without context.
+func (s *SimpleClient) NoFurtherWork() error {
+	return s.c.NoFurtherWork(context.Background())
+}
+
+// DetachJob is like Client.DetachJob, but without context.
+func (s *SimpleClient) DetachJob() error {
+	return s.c.DetachJob(context.Background())
+}
+
+// DisconnectHeadset is like Client.DisconnectHeadset, but without context.
+func (s *SimpleClient) DisconnectHeadset() error {
+	return s.c.DisconnectHeadset(context.Background())
+}
+
+// FreeHeadset is like Client.FreeHeadset, but without context.
+func (s *SimpleClient) FreeHeadset() error {
+	return s.c.FreeHeadset(context.Background())
+}
+
+// Logoff is like Client.Logoff, but without context.
+func (s *SimpleClient) Logoff() error {
+	return s.c.Logoff(context.Background())
+}
+
+// LogonWithResult is like Client.LogonWithResult, but without context.
+func (s *SimpleClient) LogonWithResult(agentName string, password string) (*Session, error) {
+	return s.c.LogonWithResult(context.Background(), agentName, password)
+}
+
+// EnsureHeadsetReserved is like Client.EnsureHeadsetReserved, but without context.
+func (s *SimpleClient) EnsureHeadsetReserved(headsetID int) error {
+	return s.c.EnsureHeadsetReserved(context.Background(), headsetID)
+}
+
+// ListJobs is like Client.ListJobs, but without context.
+func (s *SimpleClient) ListJobs(jobType JobType) ([]Job, error) {
+	return s.c.ListJobs(context.Background(), jobType)
+}
+
+// ListActiveJobs is like Client.ListActiveJobs, but without context.
+func (s *SimpleClient) ListActiveJobs(jobType JobType) ([]Job, error) {
+	return s.c.ListActiveJobs(context.Background(), jobType)
+}
+
+// ListCallLists is like Client.ListCallLists, but without context.
+func (s *SimpleClient) ListCallLists() ([]string, error) {
+	return s.c.ListCallLists(context.Background())
+}
+
+// CallLists is like Client.CallLists, but without context.
+func (s *SimpleClient) CallLists() ([]CallList, error) {
+	return s.c.CallLists(context.Background())
+}
+
+// ListCallFields is like Client.ListCallFields, but without context.
+func (s *SimpleClient) ListCallFields(listName string) ([]string, error) {
+	return s.c.ListCallFields(context.Background(), listName)
+}
+
+// CallFields is like Client.CallFields, but without context.
+func (s *SimpleClient) CallFields(listName string) ([]CallField, error) {
+	return s.c.CallFields(context.Background(), listName)
+}
+
+// SetWorkClass is like Client.SetWorkClass, but without context.
+func (s *SimpleClient) SetWorkClass(class WorkClass) error {
+	return s.c.SetWorkClass(context.Background(), class)
+}
+
+// SetUnits is like Client.SetUnits, but without context.
+func (s *SimpleClient) SetUnits(units ...string) error {
+	return s.c.SetUnits(context.Background(), units...)
+}
+
+// AttachJobWithOptions is like Client.AttachJobWithOptions, but without context.
+func (s *SimpleClient) AttachJobWithOptions(jobName string, opts JobAttachOptions) error {
+	return s.c.AttachJobWithOptions(context.Background(), jobName, opts)
+}
+
+// ListDataFields is like Client.ListDataFields, but without context.
+func (s *SimpleClient) ListDataFields(listType ListType) ([]DataField, error) {
+	return s.c.ListDataFields(context.Background(), listType)
+}
+
+// SetNotifyKeyField is like Client.SetNotifyKeyField, but without context.
+func (s *SimpleClient) SetNotifyKeyField(listType ListType, fieldName string) error {
+	return s.c.SetNotifyKeyField(context.Background(), listType, fieldName)
+}
+
+// ClearNotifyKeyField is like Client.ClearNotifyKeyField, but without context.
+func (s *SimpleClient) ClearNotifyKeyField(listType ListType) error {
+	return s.c.ClearNotifyKeyField(context.Background(), listType)
+}
+
+// ManagedCall is like Client.ManagedCall, but without context.
+func (s *SimpleClient) ManagedCall() error {
+	return s.c.ManagedCall(context.Background())
+}
+
+// ListKeys is like Client.ListKeys, but without context.
+func (s *SimpleClient) ListKeys() ([]string, error) {
+	return s.c.ListKeys(context.Background())
+}
+
+// ListKeyBindings is like Client.ListKeyBindings, but without context.
+func (s *SimpleClient) ListKeyBindings() ([]Key, error) {
+	return s.c.ListKeyBindings(context.Background())
+}
+
+// ReleaseLine is like Client.ReleaseLine, but without context.
+func (s *SimpleClient) ReleaseLine() error {
+	return s.c.ReleaseLine(context.Background())
+}
+
+// AnswerCall is like Client.AnswerCall, but without context.
+func (s *SimpleClient) AnswerCall() error {
+	return s.c.AnswerCall(context.Background())
+}
+
+// HoldCall is like Client.HoldCall, but without context.
+func (s *SimpleClient) HoldCall() error {
+	return s.c.HoldCall(context.Background())
+}
+
+// PlayMessage is like Client.PlayMessage, but without context.
+func (s *SimpleClient) PlayMessage(messageID string) error {
+	return s.c.PlayMessage(context.Background(), messageID)
+}
+
+// SendDTMF is like Client.SendDTMF, but without context.
+func (s *SimpleClient) SendDTMF(digits string) error {
+	return s.c.SendDTMF(context.Background(), digits)
+}
+
+// ReconnectCall is like Client.ReconnectCall, but without context.
+func (s *SimpleClient) ReconnectCall() error {
+	return s.c.ReconnectCall(context.Background())
+}
+
+// TransferCall is like Client.TransferCall, but without context.
+func (s *SimpleClient) TransferCall(extension string) error {
+	return s.c.TransferCall(context.Background(), extension)
+}
+
+// ConferenceCall is like Client.ConferenceCall, but without context.
+func (s *SimpleClient) ConferenceCall() error {
+	return s.c.ConferenceCall(context.Background())
+}
+
+// ManualCall is like Client.ManualCall, but without context.
+func (s *SimpleClient) ManualCall(phoneNumber string) error {
+	return s.c.ManualCall(context.Background(), phoneNumber)
+}
+
+// PreviewRecord is like Client.PreviewRecord, but without context.
+func (s *SimpleClient) PreviewRecord() error {
+	return s.c.PreviewRecord(context.Background())
+}
+
+// DialPreview is like Client.DialPreview, but without context.
+func (s *SimpleClient) DialPreview() error {
+	return s.c.DialPreview(context.Background())
+}
+
+// ListCallbackFormat is like Client.ListCallbackFormat, but without context.
+func (s *SimpleClient) ListCallbackFormat() (*CallbackFormat, error) {
+	return s.c.ListCallbackFormat(context.Background())
+}
+
+// SetCallback is like Client.SetCallback, but without context.
+func (s *SimpleClient) SetCallback(cb Callback) error {
+	return s.c.SetCallback(context.Background(), cb)
+}
+
+// ClearCallback is like Client.ClearCallback, but without context.
+func (s *SimpleClient) ClearCallback() error {
+	return s.c.ClearCallback(context.Background())
+}
+
+// ListCompletionCodes is like Client.ListCompletionCodes, but without context.
+func (s *SimpleClient) ListCompletionCodes() ([]CompletionCode, error) {
+	return s.c.ListCompletionCodes(context.Background())
+}
+
+// SetCompletionCode is like Client.SetCompletionCode, but without context.
+func (s *SimpleClient) SetCompletionCode(compCode int) error {
+	return s.c.SetCompletionCode(context.Background(), compCode)
+}
+
+// FinishedItemUnchecked is like Client.FinishedItemUnchecked, but without context.
+func (s *SimpleClient) FinishedItemUnchecked(compCode int) error {
+	return s.c.FinishedItemUnchecked(context.Background(), compCode)
+}
+
+// HeadsetStatus is like Client.HeadsetStatus, but without context.
+func (s *SimpleClient) HeadsetStatus() (HeadsetState, error) {
+	return s.c.HeadsetStatus(context.Background())
+}
+
+// ChangePassword is like Client.ChangePassword, but without context.
+func (s *SimpleClient) ChangePassword(oldPassword, newPassword string) error {
+	return s.c.ChangePassword(context.Background(), oldPassword, newPassword)
+}
+
+// EchoOn is like Client.EchoOn, but without context.
+func (s *SimpleClient) EchoOn() error {
+	return s.c.EchoOn(context.Background())
+}
+
+// EchoOff is like Client.EchoOff, but without context.
+func (s *SimpleClient) EchoOff() error {
+	return s.c.EchoOff(context.Background())
+}
+
+// LogIoStart is like Client.LogIoStart, but without context.
+func (s *SimpleClient) LogIoStart() error {
+	return s.c.LogIoStart(context.Background())
+}
+
+// LogIoStop is like Client.LogIoStop, but without context.
+func (s *SimpleClient) LogIoStop() error {
+	return s.c.LogIoStop(context.Background())
+}
+
+// SendMessage is like Client.SendMessage, but without context.
+func (s *SimpleClient) SendMessage(message string) error {
+	return s.c.SendMessage(context.Background(), message)
+}
+
+// GetAppData is like Client.GetAppData, but without context.
+func (s *SimpleClient) GetAppData(key string) (string, error) {
+	return s.c.GetAppData(context.Background(), key)
+}
+
+// SetAppData is like Client.SetAppData, but without context.
+func (s *SimpleClient) SetAppData(key, value string) error {
+	return s.c.SetAppData(context.Background(), key, value)
+}
+
+// ListState is like Client.ListState, but without context.
+func (s *SimpleClient) ListState() (*State, error) {
+	return s.c.ListState(context.Background())
+}
+
+// ListStates is like Client.ListStates, but without context.
+func (s *SimpleClient) ListStates() ([]State, error) {
+	return s.c.ListStates(context.Background())
+}
+
+// ReadField is like Client.ReadField, but without context.
+func (s *SimpleClient) ReadField(listType ListType, fieldName string) (*Field, error) {
+	return s.c.ReadField(context.Background(), listType, fieldName)
+}
+
+// RequestDataField is like Client.RequestDataField, but without context.
+func (s *SimpleClient) RequestDataField(listType ListType, fieldName string) (*Field, error) {
+	return s.c.RequestDataField(context.Background(), listType, fieldName)
+}
+
+// ReadFields is like Client.ReadFields, but without context.
+func (s *SimpleClient) ReadFields(listType ListType, fieldNames []string) ([]Field, error) {
+	return s.c.ReadFields(context.Background(), listType, fieldNames)
+}
+
+// SetDataFields is like Client.SetDataFields, but without context.
+func (s *SimpleClient) SetDataFields(listType ListType, fieldNames ...string) error {
+	return s.c.SetDataFields(context.Background(), listType, fieldNames...)
+}
+
+// WriteField is like Client.WriteField, but without context.
+func (s *SimpleClient) WriteField(listType ListType, fieldName, value string) error {
+	return s.c.WriteField(context.Background(), listType, fieldName, value)
+}
+
+// Teardown is like Client.Teardown, but without context.
+func (s *SimpleClient) Teardown() error {
+	return s.c.Teardown(context.Background())
+}
+
+// RunSession is like Client.RunSession, but without context; returned func ends the session the same way.
+func (s *SimpleClient) RunSession(cfg SessionConfig) (func() error, error) {
+	end, err := s.c.RunSession(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return end(context.Background())
+	}, nil
+}
+
+// Execute is like Client.Execute, but without context.
+func (s *SimpleClient) Execute(keyword string, args ...string) ([]string, error) {
+	return s.c.Execute(context.Background(), keyword, args...)
+}
@@ -0,0 +1,57 @@
+package apc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimpleClient(t *testing.T) {
+	var keywords []string
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		keywords = append(keywords, command.Keyword)
+		return []string{rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000")}
+	})
+	defer stop()
+
+	s := c.Simple()
+	if s.Client() != c {
+		t.Errorf("s.Client() = %p, want %p", s.Client(), c)
+	}
+
+	if err := s.Logon("agent1", "secret"); err != nil {
+		t.Errorf("s.Logon() error = %v", err)
+	}
+
+	if err := s.AttachJob("outbound1"); err != nil {
+		t.Errorf("s.AttachJob() error = %v", err)
+	}
+
+	if err := s.Logoff(); err != nil {
+		t.Errorf("s.Logoff() error = %v", err)
+	}
+
+	want := []string{"AGTLogon", "AGTAttachJob", "AGTLogoff"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("commands = %v, want %v", keywords, want)
+	}
+}
+
+func TestSimpleClient_ReadField(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{
+			rawEvent(command.Keyword, EventTypeData, command.InvokeID, "0", "M00001", "NAME,A,20,JOHN DOE"),
+			rawEvent(command.Keyword, EventTypeResponse, command.InvokeID, "0", "M00000"),
+		}
+	})
+	defer stop()
+
+	field, err := c.Simple().ReadField(ListTypeOutbound, "NAME")
+	if err != nil {
+		t.Fatalf("s.ReadField() error = %v", err)
+	}
+
+	want := &Field{Name: "NAME", Type: FieldTypeAlphanumeric, Length: 20, Value: "JOHN DOE"}
+	if !reflect.DeepEqual(field, want) {
+		t.Errorf("s.ReadField() = %#v, want %#v", field, want)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build go1.21
+// +build go1.21
+
+package apc
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithSlogLogger returns an Option with the standard library structured logger,
+// it's the alternative of WithLogger that doesn't require zap.
+func WithSlogLogger(logger *slog.Logger) Option {
+	return func(options *Options) {
+		options.LogLevel = LogLevelDebug
+		options.LogHandler = func(entry LogEntry) {
+			attrs := make([]slog.Attr, 0, len(entry.Fields))
+			for k, v := range entry.Fields {
+				attrs = append(attrs, slog.Any(k, v))
+			}
+
+			var level slog.Level
+			switch entry.Level {
+			case LogLevelDebug:
+				level = slog.LevelDebug
+			case LogLevelInfo:
+				level = slog.LevelInfo
+			case LogLevelError:
+				level = slog.LevelError
+			case LogLevelNone:
+				return
+			}
+
+			logger.LogAttrs(context.Background(), level, entry.Message, attrs...)
+		}
+	}
+}
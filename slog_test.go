@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package apc
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	options := &Options{}
+	WithSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))(options)
+
+	l := newLogger(options.LogLevel, options.LogHandler)
+	l.log(newLogEntry(LogLevelError, "Keepalive has failed!", map[string]interface{}{"error": "timeout"}))
+
+	if got := buf.String(); !strings.Contains(got, "level=ERROR") || !strings.Contains(got, "error=timeout") {
+		t.Errorf("log = %q, want error level w/ error attribute", got)
+	}
+}
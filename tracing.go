@@ -0,0 +1,46 @@
+package apc
+
+import (
+	"context"
+	"errors"
+)
+
+// Tracer starts a span per each command. It allows to plug in OpenTelemetry or any other tracing library
+// without adding the dependency to this package, e.g. w/ adapter over trace.Tracer:
+//
+//	func (t otelTracer) Start(ctx context.Context, keyword string) apc.Span {
+//		_, span := t.tracer.Start(ctx, keyword)
+//		return otelSpan{span}
+//	}
+type Tracer interface {
+	// Start starts the span named after the command keyword.
+	Start(ctx context.Context, keyword string) Span
+}
+
+// Span is the span of a single command execution.
+type Span interface {
+	// SetAttribute records the command attribute: apc.invoke_id, apc.segments or apc.error_code.
+	SetAttribute(key string, value interface{})
+	// End ends the span, err is the result of the command.
+	End(err error)
+}
+
+// traceCommand starts the span of the command, returned func ends it with the result.
+func (c *Client) traceCommand(ctx context.Context, keyword string, invokeID uint32, segments int) func(err error) {
+	if c.opts.Tracer == nil {
+		return func(error) {}
+	}
+
+	span := c.opts.Tracer.Start(ctx, keyword)
+	span.SetAttribute("apc.invoke_id", int(invokeID))
+	span.SetAttribute("apc.segments", segments)
+
+	return func(err error) {
+		var avayaErr AvayaError
+		if errors.As(err, &avayaErr) {
+			span.SetAttribute("apc.error_code", avayaErr.Code)
+		}
+
+		span.End(err)
+	}
+}
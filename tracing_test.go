@@ -0,0 +1,75 @@
+package apc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	mu         sync.Mutex
+	keyword    string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+	s.ended = true
+}
+
+type testTracer struct {
+	spans chan *testSpan
+}
+
+func (t testTracer) Start(ctx context.Context, keyword string) Span {
+	span := &testSpan{keyword: keyword, attributes: make(map[string]interface{})}
+	t.spans <- span
+	return span
+}
+
+func TestClient_traceCommand(t *testing.T) {
+	c, stop := newRespondingClient(t, func(command Event) []string {
+		return []string{rawEvent("AGTAttachJob", EventTypeResponse, command.InvokeID, "1", "E28804")}
+	})
+	defer stop()
+
+	tracer := testTracer{spans: make(chan *testSpan, 1)}
+	c.opts.Tracer = tracer
+
+	err := c.AttachJob(context.Background(), "outbound1")
+	span := <-tracer.spans
+
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	if span.keyword != "AGTAttachJob" {
+		t.Errorf("span.keyword = %v, want AGTAttachJob", span.keyword)
+	}
+
+	if !span.ended || !errors.Is(span.err, err) {
+		t.Errorf("span ended = %v w/ error %v, want ended w/ error %v", span.ended, span.err, err)
+	}
+
+	want := map[string]interface{}{
+		"apc.invoke_id":  1,
+		"apc.segments":   1,
+		"apc.error_code": "E28804",
+	}
+	if !reflect.DeepEqual(span.attributes, want) {
+		t.Errorf("span.attributes = %v, want %v", span.attributes, want)
+	}
+}